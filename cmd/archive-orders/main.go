@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"atlasbilliards.com/pkg/cli"
+	"atlasbilliards.com/pkg/shopify"
+)
+
+var (
+	before string
+	dryRun bool
+)
+
+func init() {
+	flag.StringVar(&before, "before", "", "-before <YYYY-MM-DD, only orders closed before this day>")
+	flag.BoolVar(&dryRun, "dry-run", false, "-dry-run (list the orders without tagging them)")
+	flag.Parse()
+}
+
+func main() {
+	cli.Run(run)
+}
+
+func run(ctx context.Context) error {
+	var err error
+	opts := shopify.ArchiveOptions{DryRun: dryRun}
+	if before != "" {
+		opts.ClosedBefore, err = time.Parse("2006-01-02", before)
+		if err != nil {
+			return cli.Usage(fmt.Errorf("-before: %w", err))
+		}
+	}
+	s, err := shopify.NewService(cli.Config())
+	if err != nil {
+		return err
+	}
+	sum, err := s.OrderClosedAddArchiveTag(ctx, opts)
+	var te *shopify.TagError
+	if err != nil && !errors.As(err, &te) {
+		return err
+	}
+
+	verb := "tagged"
+	if sum.DryRun {
+		verb = "would tag"
+	}
+	for _, id := range sum.Tagged {
+		fmt.Printf("%s %s\n", verb, id)
+	}
+	for _, id := range sum.NotExported {
+		fmt.Printf("skipped %s (not exported)\n", id)
+	}
+	for _, f := range sum.Failed {
+		fmt.Printf("failed %s: %s\n", f.ID, f.Error)
+	}
+	fmt.Printf("%s %d, skipped %d not exported, %d failed\n", verb, len(sum.Tagged), len(sum.NotExported), len(sum.Failed))
+	if len(sum.Failed) > 0 {
+		return cli.ErrPartial
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"atlasbilliards.com/pkg/cli"
+	"atlasbilliards.com/pkg/shopify"
+)
+
+var (
+	out     string
+	resolve string
+)
+
+func init() {
+	flag.StringVar(&out, "out", "duplicate_skus.csv", "-out <audit csv>")
+	flag.StringVar(&resolve, "resolutions", "sku_resolutions.csv", "-resolutions <sku,winner csv used by upload-inventory>")
+	flag.Parse()
+}
+
+func main() {
+	cli.Run(run)
+}
+
+func run(ctx context.Context) error {
+	s, err := shopify.NewService(cli.Config())
+	if err != nil {
+		return err
+	}
+	res, err := shopify.LoadSkuResolutions(resolve)
+	if err != nil {
+		return cli.Usage(err)
+	}
+	dd, err := s.DuplicateSkus(ctx)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"sku", "inventory_item_id", "variant_id", "product", "variant", "location", "available", "winner"})
+	unresolved := 0
+	for _, d := range dd {
+		winner, err := res.Pick(d.Sku, d.Items)
+		if err != nil {
+			unresolved++
+		}
+		for _, i := range d.Items {
+			product := ""
+			if i.Variant.Product != nil {
+				product = i.Variant.Product.Title
+			}
+			won := ""
+			if winner != nil && winner.ID == i.ID {
+				won = "yes"
+			}
+			for _, l := range i.InventoryLevels {
+				w.Write([]string{d.Sku, i.ID, i.Variant.ID, product, i.Variant.DisplayName, l.Location.Name, strconv.Itoa(l.Available), won})
+			}
+			if len(i.InventoryLevels) == 0 {
+				w.Write([]string{d.Sku, i.ID, i.Variant.ID, product, i.Variant.DisplayName, "", "", won})
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("%s: %w", out, err)
+	}
+	fmt.Printf("%d duplicated skus, %d without a resolution in %s, written to %s\n", len(dd), unresolved, resolve, out)
+	return f.Close()
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
+	"errors"
 	"flag"
+	"fmt"
 	"io"
+	"log"
 	"os"
 	"regexp"
 	"strings"
 
+	"atlasbilliards.com/pkg/cli"
 	"atlasbilliards.com/pkg/shopify"
 )
 
@@ -19,19 +24,22 @@ func init() {
 }
 
 func main() {
-	var err error
+	cli.Run(run)
+}
+
+func run(ctx context.Context) error {
 	// clean csv file
 	if clean {
 		fsol, err := os.OpenFile("solomon_members.csv", os.O_RDONLY, 0644)
 		if err != nil {
-			panic(err)
+			return err
 		}
 		defer fsol.Close()
 		rsol := csv.NewReader(fsol)
 
 		fclean, err := os.OpenFile("solomon_members_clean.csv", os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
 		if err != nil {
-			panic(err)
+			return err
 		}
 		defer fclean.Close()
 		wclean := csv.NewWriter(fclean)
@@ -42,7 +50,7 @@ func main() {
 				break
 			}
 			if err != nil {
-				panic(err)
+				return fmt.Errorf("solomon_members.csv: %w", err)
 			}
 			rowsClean := []string{}
 			for i, v := range rows {
@@ -59,13 +67,18 @@ func main() {
 		}
 	}
 
-	conf := shopify.Config{
-		AccessToken: os.Getenv("ATLAS_BILLIARDS_SHOPIFY_ACCESS_TOKEN"),
-		Shop:        os.Getenv("ATLAS_BILLIARDS_SHOPIFY_SHOP"),
-	}
-	s := shopify.NewService(conf)
-	err = s.SolomonMembersMapMetafields()
+	s, err := shopify.NewService(cli.Config())
 	if err != nil {
-		panic(err)
+		return err
+	}
+	err = s.SolomonMembersMapMetafields(ctx)
+	var be *shopify.BatchError
+	if errors.As(err, &be) {
+		for _, f := range be.Failures {
+			log.Printf("refused: %s", f)
+		}
+		log.Printf("%d customers were not updated", len(be.Failures))
+		return cli.ErrPartial
 	}
+	return err
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"atlasbilliards.com/pkg/cli"
+	"atlasbilliards.com/pkg/shopify"
+)
+
+var (
+	backup string
+	runID  string
+	force  bool
+	dryRun bool
+)
+
+func init() {
+	flag.StringVar(&backup, "backup", "shopify_backup.csv", "-backup <backup file written by upload-inventory>")
+	flag.StringVar(&runID, "run", "", "-run <run ID of the upload to undo, lists the runs when empty>")
+	flag.BoolVar(&force, "force", false, "-force (restore even where stock moved since the upload)")
+	flag.BoolVar(&dryRun, "dry-run", false, "-dry-run (report without changing stock)")
+	flag.Parse()
+}
+
+func main() {
+	cli.Run(run)
+}
+
+func run(ctx context.Context) error {
+	if runID == "" {
+		entries, err := shopify.ReadInventoryBackup(backup)
+		if err != nil {
+			return err
+		}
+		counts := map[string]int{}
+		runs := []shopify.BackupEntry{}
+		for _, e := range entries {
+			if counts[e.RunID] == 0 {
+				runs = append(runs, e)
+			}
+			counts[e.RunID]++
+		}
+		for _, e := range runs {
+			fmt.Printf("%s  %s  %d SKUs\n", e.RunID, e.Time.Local().Format("2006-01-02 15:04"), counts[e.RunID])
+		}
+		return nil
+	}
+
+	s, err := shopify.NewService(cli.Config())
+	if err != nil {
+		return err
+	}
+	rep, err := s.RestoreInventory(ctx, shopify.RestoreInventoryOptions{
+		Backup: backup,
+		RunID:  runID,
+		Force:  force,
+		DryRun: dryRun,
+	})
+	if rep != nil {
+		fmt.Print(rep.Summary())
+	}
+	if err != nil {
+		return err
+	}
+	if !dryRun {
+		fmt.Printf("restore backed up as run %s\n", rep.RunID)
+	}
+	return nil
+}
@@ -1,21 +1,73 @@
 package main
 
 import (
-	"os"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
 
+	"atlasbilliards.com/pkg/cli"
 	"atlasbilliards.com/pkg/shopify"
 )
 
+var (
+	out   string
+	state string
+	since string
+	bulk  bool
+)
+
+func init() {
+	flag.StringVar(&out, "out", ".", "-out <directory for the Solomon files>")
+	flag.StringVar(&state, "state", "shopify-export.state.json", "-state <file holding the last exported order>")
+	flag.StringVar(&since, "since", "", "-since <YYYY-MM-DD, first run only: export orders updated on or after this day>")
+	flag.BoolVar(&bulk, "bulk", false, "-bulk (read the orders with one bulk operation instead of paging)")
+	flag.Parse()
+}
+
 func main() {
+	cli.Run(run)
+}
+
+func run(ctx context.Context) error {
 	var err error
-	conf := shopify.Config{
-		AccessToken: os.Getenv("ATLAS_BILLIARDS_SHOPIFY_ACCESS_TOKEN"),
-		Shop:        os.Getenv("ATLAS_BILLIARDS_SHOPIFY_SHOP"),
+	opts := shopify.OrderExportOptions{
+		Query:     "test:false AND fulfillment_status:fulfilled AND -financial_status:authorized AND tag_not:exported AND tag_not:archived AND tag:printed",
+		StateFile: state,
+	}
+	if since != "" {
+		opts.Since, err = time.Parse("2006-01-02", since)
+		if err != nil {
+			return cli.Usage(fmt.Errorf("-since: %w", err))
+		}
+	}
+	conf := cli.Config()
+	conf.OutputDir = out
+	conf.Bulk = bulk
+	s, err := shopify.NewService(conf)
+	if err != nil {
+		return err
+	}
+	rep, err := s.GenSolonomFiles(ctx, opts)
+	if rep == nil {
+		return err
+	}
+	// an interrupted export still reports the orders it wrote
+	log.Printf("exported %d orders", len(rep.Exported))
+	if len(rep.Untagged) > 0 {
+		if err == nil {
+			for _, f := range rep.Untagged {
+				log.Printf("not tagged: %s: %s", f.ID, f.Error)
+			}
+		}
+		log.Printf("%d orders are exported but not tagged, they will be retried on the next run", len(rep.Untagged))
 	}
-	s := shopify.NewService(conf)
-	query := "test:false AND fulfillment_status:fulfilled AND -financial_status:authorized AND tag_not:exported AND tag_not:archived AND tag:printed AND created_at:2023-02-28"
-	err = s.GenSolonomFiles(query)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if len(rep.Untagged) > 0 {
+		return cli.ErrPartial
 	}
+	return nil
 }
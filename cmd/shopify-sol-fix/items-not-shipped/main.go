@@ -9,24 +9,30 @@ import (
 	"strconv"
 	"strings"
 
+	"atlasbilliards.com/pkg/cli"
 	"atlasbilliards.com/pkg/shopify"
 	"github.com/machinebox/graphql"
 )
 
 func main() {
-	token := os.Getenv("ATLAS_BILLIARDS_SHOPIFY_ACCESS_TOKEN")
-	endpoint := fmt.Sprintf("https://%s.myshopify.com/admin/api/2023-01/graphql.json", os.Getenv("ATLAS_BILLIARDS_SHOPIFY_SHOP"))
-	client := graphql.NewClient(endpoint)
+	cli.Run(run)
+}
+
+func run(ctx context.Context) error {
+	s, err := shopify.NewService(cli.Config())
+	if err != nil {
+		return err
+	}
 
 	f, err := os.OpenFile("../orders.csv", os.O_RDONLY, 0644)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer f.Close()
 
 	fOut, err := os.OpenFile("not-shipped.csv", os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer fOut.Close()
 
@@ -58,14 +64,14 @@ func main() {
 			break
 		}
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("../orders.csv: %w", err)
 		}
 		// rows[0] = "5105750507771"
 		oid := fmt.Sprintf("gid://shopify/Order/%s", rows[0])
 
-		rq := graphql.NewRequest(fmt.Sprintf(`
-			{
-				order(id:"%s"){
+		rq := graphql.NewRequest(`
+			query order($id: ID!) {
+				order(id: $id){
 					id
 					order_number:name
 					customer{
@@ -103,13 +109,13 @@ func main() {
 					closed
 				}
 			}
-		`, oid))
-		rq.Header.Add("X-Shopify-Access-Token", token)
+		`)
+		rq.Var("id", oid)
 		var rs response
 		// var i GetRaw
-		err = client.Run(context.Background(), rq, &rs)
+		err = s.Run(ctx, rq, &rs)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("order %s: %w", oid, err)
 		}
 		for _, rfs := range rs.Order.Refunds {
 			for _, v := range rfs.RefundLineItems.Nodes {
@@ -122,4 +128,5 @@ func main() {
 			}
 		}
 	}
+	return nil
 }
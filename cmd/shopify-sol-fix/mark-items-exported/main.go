@@ -7,30 +7,27 @@ import (
 	"io"
 	"os"
 
+	"atlasbilliards.com/pkg/cli"
 	"atlasbilliards.com/pkg/shopify"
-	"github.com/machinebox/graphql"
 )
 
 func main() {
-	token := os.Getenv("ATLAS_BILLIARDS_SHOPIFY_ACCESS_TOKEN")
-	endpoint := fmt.Sprintf("https://%s.myshopify.com/admin/api/2023-01/graphql.json", os.Getenv("ATLAS_BILLIARDS_SHOPIFY_SHOP"))
-	conf := shopify.Config{
-		AccessToken: os.Getenv("ATLAS_BILLIARDS_SHOPIFY_ACCESS_TOKEN"),
-		Shop:        os.Getenv("ATLAS_BILLIARDS_SHOPIFY_SHOP"),
+	cli.Run(run)
+}
+
+func run(ctx context.Context) error {
+	s, err := shopify.NewService(cli.Config())
+	if err != nil {
+		return err
 	}
-	s := shopify.NewService(conf)
-	client := graphql.NewClient(endpoint)
 
 	f, err := os.OpenFile("../orders.csv", os.O_RDONLY, 0644)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer f.Close()
 
-	type response struct {
-		Order shopify.Order `json:"order"`
-	}
-
+	ids := []string{}
 	r := csv.NewReader(f)
 	for {
 		rows, err := r.Read()
@@ -38,148 +35,9 @@ func main() {
 			break
 		}
 		if err != nil {
-			panic(err)
-		}
-		oid := fmt.Sprintf("%s/%s", "gid://shopify/Order", rows[0])
-		rq := graphql.NewRequest(fmt.Sprintf(`
-			{
-				order(id:"%s"){
-					id
-					order_number:name
-					customer{
-						id
-						email
-						firstName
-						lastName
-						defaultAddress{
-							address1
-							address2
-							city
-							state:provinceCode
-							zip
-							countryCodeV2
-							region:provinceCode
-							company
-						}
-						addresses{
-							address1
-							address2
-							city
-							state:provinceCode
-							zip
-							countryCodeV2
-							region:provinceCode
-							company
-						}
-						phone
-						taxExempt
-						taxExemptions
-						customer_number:metafield(namespace: "custom", key:"customer_number") {
-							value
-						}
-						tax_exempt_id:metafield(namespace: "custom", key: "tax_exempt_id") {
-							value
-						}
-						tags
-						createdAt
-					}
-					billingAddress{
-						firstName
-						lastName
-						phone
-						address1
-						address2
-						city
-						state:provinceCode
-						zip
-						countryCodeV2
-						region:provinceCode
-						company
-					}
-					billingAddressMatchesShippingAddress
-					shippingAddress{
-						firstName
-						lastName
-						phone
-						address1
-						address2
-						city
-						state:provinceCode
-						zip
-						countryCodeV2
-						region:provinceCode
-						company
-					}
-					paymentTerms{
-						paymentTermsName
-						paymentTermsType
-					}
-					phone
-					email
-					createdAt
-					processedAt
-					closedAt
-					subtotalPriceSet{
-						presentmentMoney{
-							amount
-							currencyCode
-						}
-					}
-					totalTaxSet{
-						presentmentMoney{
-							amount
-							currencyCode
-						}
-					}
-					totalShippingPriceSet{
-						presentmentMoney{
-							amount
-							currencyCode
-						}
-					}
-					totalPriceSet{
-						presentmentMoney{
-							amount
-							currencyCode
-						}
-					}
-					totalReceivedSet {
-						presentmentMoney {
-							amount
-							currencyCode
-						}
-					}
-					lineItems(first: 250) {
-						nodes{
-							id
-							product{
-								title
-								handle
-							}
-							variant{
-								title
-								sku
-								price
-								weight
-							}
-							quantity
-						}
-					}
-					displayFinancialStatus
-					displayFulfillmentStatus
-					closed
-				}
-			}
-		`, oid))
-		rq.Header.Add("X-Shopify-Access-Token", token)
-		var rs response
-		err = client.Run(context.Background(), rq, &rs)
-		if err != nil {
-			panic(err)
-		}
-		err = s.UpdateOrderTags(rs.Order, "exported")
-		if err != nil {
-			panic(err)
+			return fmt.Errorf("../orders.csv: %w", err)
 		}
+		ids = append(ids, fmt.Sprintf("%s/%s", "gid://shopify/Order", rows[0]))
 	}
+	return s.AddTags(ctx, ids, "exported")
 }
@@ -1,20 +1,52 @@
 package main
 
 import (
-	"os"
+	"context"
+	"flag"
+	"fmt"
 
+	"atlasbilliards.com/pkg/cli"
 	"atlasbilliards.com/pkg/shopify"
 )
 
+var (
+	file     string
+	location string
+	dryRun   bool
+	report   string
+	resolve  string
+	loose    bool
+)
+
+func init() {
+	flag.StringVar(&file, "file", "ABS Inventory Quantities.txt", "-file <solomon inventory file>")
+	flag.StringVar(&location, "location", "", "-location <location id or name>")
+	flag.BoolVar(&dryRun, "dry-run", false, "-dry-run (write the diff report without changing stock)")
+	flag.StringVar(&report, "report", "inventory_diff.csv", "-report <diff report csv>")
+	flag.BoolVar(&loose, "loose-sku", false, "-loose-sku (match SKUs ignoring case)")
+	flag.StringVar(&resolve, "resolutions", "sku_resolutions.csv", "-resolutions <sku,winner csv for duplicated skus, see audit-skus>")
+	flag.Parse()
+}
+
 func main() {
-	var err error
-	conf := shopify.Config{
-		AccessToken: os.Getenv("ATLAS_BILLIARDS_SHOPIFY_ACCESS_TOKEN"),
-		Shop:        os.Getenv("ATLAS_BILLIARDS_SHOPIFY_SHOP"),
-	}
-	s := shopify.NewService(conf)
-	err = s.UploadInventory()
+	cli.Run(run)
+}
+
+func run(ctx context.Context) error {
+	s, err := shopify.NewService(cli.Config())
 	if err != nil {
-		panic(err)
+		return err
+	}
+	rep, err := s.UploadInventory(ctx, shopify.UploadInventoryOptions{
+		File:        file,
+		Location:    location,
+		DryRun:      dryRun,
+		Report:      report,
+		LooseSku:    loose,
+		Resolutions: resolve,
+	})
+	if rep != nil {
+		fmt.Print(rep.Summary())
 	}
+	return err
 }
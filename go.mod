@@ -2,8 +2,9 @@ module atlasbilliards.com
 
 go 1.17
 
+require github.com/machinebox/graphql v0.2.2
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/machinebox/graphql v0.2.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 )
@@ -0,0 +1,108 @@
+// Package cli is what the commands in cmd share: the Shopify config from
+// the environment and exit codes a scheduler can act on.
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"atlasbilliards.com/pkg/shopify"
+)
+
+const (
+	ExitOK = 0
+	// ExitFailure means the run stopped on an error.
+	ExitFailure = 1
+	// ExitUsage means bad flags or configuration, nothing was sent to
+	// Shopify.
+	ExitUsage = 2
+	// ExitPartial means the run finished but Shopify refused some of the
+	// items, they are listed in the output.
+	ExitPartial = 3
+	// ExitInterrupted means the run was stopped by SIGINT or SIGTERM after
+	// writing out what it had done so far.
+	ExitInterrupted = 130
+)
+
+// ErrPartial is for a command that already listed what Shopify refused.
+var ErrPartial = errors.New("some items failed, see above")
+
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+} // ./Error
+
+func (e usageError) Unwrap() error {
+	return e.err
+} // ./Unwrap
+
+// Usage marks err as a mistake on the command line, e.g. a malformed
+// flag value.
+func Usage(err error) error {
+	if err == nil {
+		return nil
+	}
+	return usageError{err: err}
+} // ./Usage
+
+// Code is the exit code for err.
+func Code(err error) int {
+	var ue usageError
+	var te *shopify.TagError
+	var be *shopify.BatchError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.As(err, &ue), errors.Is(err, shopify.ErrInvalidConfig):
+		return ExitUsage
+	case errors.Is(err, ErrPartial), errors.As(err, &te), errors.As(err, &be):
+		return ExitPartial
+	}
+	return ExitFailure
+} // ./Code
+
+// Run calls run with a context that is canceled on SIGINT or SIGTERM and
+// exits with the Code of its error. A second signal kills the command
+// right away.
+func Run(run func(ctx context.Context) error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err := run(ctx)
+	stop()
+	Exit(err)
+} // ./Run
+
+// Exit prints err, if any, and exits with its Code.
+func Exit(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filepath.Base(os.Args[0]), err)
+	}
+	os.Exit(Code(err))
+} // ./Exit
+
+// Config is the shop, token and locations from the
+// ATLAS_BILLIARDS_SHOPIFY_* variables.
+func Config() shopify.Config {
+	conf := shopify.Config{
+		AccessToken: os.Getenv("ATLAS_BILLIARDS_SHOPIFY_ACCESS_TOKEN"),
+		Shop:        os.Getenv("ATLAS_BILLIARDS_SHOPIFY_SHOP"),
+	}
+	if v := os.Getenv("ATLAS_BILLIARDS_SHOPIFY_LOCATIONS"); v != "" {
+		conf.Locations = strings.Split(v, ",")
+	}
+	return conf
+} // ./Config
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"atlasbilliards.com/pkg/shopify"
+)
+
+func TestCode(t *testing.T) {
+	_, cerr := shopify.NewService(shopify.Config{})
+	for _, c := range []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitOK},
+		{errors.New("connection refused"), ExitFailure},
+		{Usage(errors.New("-since: bad date")), ExitUsage},
+		{cerr, ExitUsage},
+		{ErrPartial, ExitPartial},
+		{fmt.Errorf("archive: %w", &shopify.TagError{Mutation: "tagsAdd"}), ExitPartial},
+		{&shopify.BatchError{}, ExitPartial},
+		{&shopify.UserErrorsError{Mutation: "customerUpdate"}, ExitFailure},
+		{fmt.Errorf("order 5001: %w", context.Canceled), ExitInterrupted},
+		{context.DeadlineExceeded, ExitFailure},
+	} {
+		if got := Code(c.err); got != c.want {
+			t.Errorf("Code(%v) = %d, want %d", c.err, got, c.want)
+		}
+	}
+} // ./TestCode
@@ -0,0 +1,267 @@
+// Package money is a fixed-point amount of a currency, so that totals
+// exported to Solomon match Shopify to the penny.
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Money is an amount in the minor units of its currency, e.g. cents.
+// The zero value is zero in no particular currency and can be added to
+// an amount of any currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// exponents lists the ISO 4217 currencies whose minor unit is not 1/100.
+var exponents = map[string]int{
+	"BHD": 3,
+	"BIF": 0,
+	"CLP": 0,
+	"DJF": 0,
+	"GNF": 0,
+	"IQD": 3,
+	"ISK": 0,
+	"JOD": 3,
+	"JPY": 0,
+	"KMF": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"LYD": 3,
+	"OMR": 3,
+	"PYG": 0,
+	"RWF": 0,
+	"TND": 3,
+	"UGX": 0,
+	"UYI": 0,
+	"VND": 0,
+	"VUV": 0,
+	"XAF": 0,
+	"XOF": 0,
+	"XPF": 0,
+}
+
+// Exponent returns the number of decimal places of currency.
+func Exponent(currency string) int {
+	if e, ok := exponents[strings.ToUpper(currency)]; ok {
+		return e
+	}
+	return 2
+} // ./Exponent
+
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+} // ./New
+
+// Parse reads a decimal amount such as "-12.345" and rounds it half away
+// from zero to the minor unit of currency. This is the rounding used for
+// every amount Shopify sends us.
+func Parse(s, currency string) (Money, error) {
+	m := Money{Currency: currency}
+	v := strings.TrimSpace(s)
+	neg := false
+	switch {
+	case strings.HasPrefix(v, "-"):
+		neg = true
+		v = v[1:]
+	case strings.HasPrefix(v, "+"):
+		v = v[1:]
+	}
+	whole, frac := v, ""
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		whole, frac = v[:i], v[i+1:]
+	}
+	if whole == "" && frac == "" || !digits(whole) || !digits(frac) {
+		return m, fmt.Errorf("money: invalid amount %q", s)
+	}
+
+	exp := Exponent(currency)
+	round := false
+	if len(frac) > exp {
+		round = frac[exp] >= '5'
+		frac = frac[:exp]
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	var n int64
+	for _, c := range whole + frac {
+		d := int64(c - '0')
+		if n > (math.MaxInt64-d)/10 {
+			return m, fmt.Errorf("money: amount %q out of range", s)
+		}
+		n = n*10 + d
+	}
+	if round {
+		if n == math.MaxInt64 {
+			return m, fmt.Errorf("money: amount %q out of range", s)
+		}
+		n++
+	}
+	if neg {
+		n = -n
+	}
+	m.Amount = n
+	return m, nil
+} // ./Parse
+
+// MustParse is like Parse but panics on error. Meant for literals.
+func MustParse(s, currency string) Money {
+	m, err := Parse(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+} // ./MustParse
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+} // ./digits
+
+// String formats the amount with exactly as many decimals as its
+// currency has, e.g. "12.30". The currency code is not included.
+func (m Money) String() string {
+	exp := Exponent(m.Currency)
+	n := m.Amount
+	sign := ""
+	if n < 0 {
+		sign = "-"
+	}
+	// format the magnitude without negating, MinInt64 has no positive
+	s := strings.TrimPrefix(fmt.Sprint(n), "-")
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+} // ./String
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+} // ./IsZero
+
+// Add returns m + o. It panics if both have a currency and they differ.
+func (m Money) Add(o Money) Money {
+	cur := m.currency(o)
+	return Money{Amount: m.Amount + o.Amount, Currency: cur}
+} // ./Add
+
+// Sub returns m - o. It panics if both have a currency and they differ.
+func (m Money) Sub(o Money) Money {
+	cur := m.currency(o)
+	return Money{Amount: m.Amount - o.Amount, Currency: cur}
+} // ./Sub
+
+// Mul returns m times a quantity.
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+} // ./Mul
+
+// Div returns m divided by n, rounded half away from zero.
+func (m Money) Div(n int64) Money {
+	q, r := m.Amount/n, m.Amount%n
+	if r != 0 && 2*abs(r) >= abs(n) {
+		// division truncates toward zero, step one further away from it
+		if (m.Amount < 0) != (n < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Money{Amount: q, Currency: m.Currency}
+} // ./Div
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+} // ./abs
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than o.
+func (m Money) Cmp(o Money) int {
+	m.currency(o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+} // ./Cmp
+
+func (m Money) currency(o Money) string {
+	switch {
+	case m.Currency == "":
+		return o.Currency
+	case o.Currency == "" || strings.EqualFold(m.Currency, o.Currency):
+		return m.Currency
+	}
+	panic(fmt.Sprintf("money: mixing %s and %s", m.Currency, o.Currency))
+} // ./currency
+
+type moneyV2 struct {
+	Amount       json.RawMessage `json:"amount"`
+	CurrencyCode string          `json:"currencyCode"`
+}
+
+// MarshalJSON writes m as a Shopify MoneyV2 object.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount       string `json:"amount"`
+		CurrencyCode string `json:"currencyCode,omitempty"`
+	}{m.String(), m.Currency})
+} // ./MarshalJSON
+
+// UnmarshalJSON accepts a MoneyV2 object as well as the bare Decimal and
+// Money scalars Shopify uses for fields like ProductVariant.price.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	cur := m.Currency
+	raw := json.RawMessage(data)
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		var v moneyV2
+		err := json.Unmarshal(data, &v)
+		if err != nil {
+			return err
+		}
+		if v.Amount == nil {
+			return nil
+		}
+		raw = v.Amount
+		cur = v.CurrencyCode
+	}
+	s := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	v, err := Parse(s, cur)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+} // ./UnmarshalJSON
+
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+} // ./MarshalText
+
+// UnmarshalText parses a bare amount in the receiver's currency.
+func (m *Money) UnmarshalText(text []byte) error {
+	v, err := Parse(string(text), m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+} // ./UnmarshalText
@@ -0,0 +1,98 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in, cur string
+		want    int64
+		str     string
+	}{
+		{"12.34", "USD", 1234, "12.34"},
+		{"15.0", "USD", 1500, "15.00"},
+		{"0.1", "", 10, "0.10"},
+		{"1000.005", "USD", 100001, "1000.01"},
+		{"1000.004999", "USD", 100000, "1000.00"},
+		{"-2.675", "USD", -268, "-2.68"},
+		{"-0.004", "USD", 0, "0.00"},
+		{".5", "USD", 50, "0.50"},
+		{"1500.5", "JPY", 1501, "1501"},
+		{"1.2345", "KWD", 1235, "1.235"},
+		{"-0.05", "USD", -5, "-0.05"},
+	} {
+		m, err := Parse(tc.in, tc.cur)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		if m.Amount != tc.want || m.String() != tc.str {
+			t.Errorf("Parse(%q, %s) = %d %q, want %d %q", tc.in, tc.cur, m.Amount, m, tc.want, tc.str)
+		}
+	}
+	for _, in := range []string{"", ".", "1,000.00", "1e3", "abc", "99999999999999999999"} {
+		_, err := Parse(in, "USD")
+		if err == nil {
+			t.Errorf("Parse(%q) did not fail", in)
+		}
+	}
+} // ./TestParse
+
+func TestArithmetic(t *testing.T) {
+	a := MustParse("0.10", "USD")
+	sum := Money{}
+	for i := 0; i < 3; i++ {
+		sum = sum.Add(a)
+	}
+	if sum.String() != "0.30" || sum.Currency != "USD" {
+		t.Errorf("0.10 * 3 = %s %s", sum, sum.Currency)
+	}
+	if got := a.Mul(3); got != sum {
+		t.Errorf("Mul = %v, want %v", got, sum)
+	}
+	for _, tc := range []struct {
+		in   string
+		n    int64
+		want string
+	}{
+		{"10.00", 3, "3.33"},
+		{"0.05", 2, "0.03"},
+		{"-0.05", 2, "-0.03"},
+		{"0.05", -2, "-0.03"},
+		{"20.00", 4, "5.00"},
+	} {
+		if got := MustParse(tc.in, "USD").Div(tc.n).String(); got != tc.want {
+			t.Errorf("%s / %d = %s, want %s", tc.in, tc.n, got, tc.want)
+		}
+	}
+	if MustParse("1.00", "USD").Cmp(MustParse("0.99", "USD")) != 1 {
+		t.Error("Cmp")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("adding USD and CAD did not panic")
+		}
+	}()
+	MustParse("1", "USD").Add(MustParse("1", "CAD"))
+} // ./TestArithmetic
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Set    Money `json:"set"`
+		Scalar Money `json:"scalar"`
+		Null   Money `json:"null"`
+	}
+	err := json.Unmarshal([]byte(`{"set":{"amount":"19.999","currencyCode":"USD"},"scalar":"24.99","null":null}`), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Set != (Money{2000, "USD"}) || v.Scalar != (Money{2499, ""}) || !v.Null.IsZero() {
+		t.Errorf("got %+v", v)
+	}
+	out, _ := json.Marshal(v.Set)
+	if string(out) != `{"amount":"20.00","currencyCode":"USD"}` {
+		t.Errorf("marshal = %s", out)
+	}
+} // ./TestJSON
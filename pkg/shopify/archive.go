@@ -0,0 +1,113 @@
+package shopify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type ArchiveOptions struct {
+	// ClosedBefore limits the sweep to orders closed before it. Zero means
+	// every closed order.
+	ClosedBefore time.Time
+	// DryRun only reports what would be tagged.
+	DryRun bool
+	// ExportedTag marks orders already sent to Solomon. Defaults to
+	// "exported".
+	ExportedTag string
+	// ArchivedTag defaults to "archived".
+	ArchivedTag string
+}
+
+type ArchiveSummary struct {
+	DryRun bool
+	// Tagged lists the closed, exported orders given the archived tag, or
+	// that would be in a dry run.
+	Tagged []string
+	// NotExported lists the closed orders left alone because they have not
+	// been exported yet.
+	NotExported []string
+	// Failed lists the orders the tag could not be added to.
+	Failed []TagFailure
+}
+
+// OrderClosedAddArchiveTag sweeps closed orders that are not archived yet
+// and adds the archived tag to those already exported, so that the export
+// query can leave them out with tag_not:archived. When some orders could
+// not be tagged the summary is returned along with a *TagError.
+func (s Service) OrderClosedAddArchiveTag(ctx context.Context, opts ArchiveOptions) (*ArchiveSummary, error) {
+	if opts.ExportedTag == "" {
+		opts.ExportedTag = "exported"
+	}
+	if opts.ArchivedTag == "" {
+		opts.ArchivedTag = "archived"
+	}
+	query := "status:closed AND tag_not:" + SearchValue(opts.ArchivedTag)
+	if !opts.ClosedBefore.IsZero() {
+		query += fmt.Sprintf(" AND closed_at:<'%s'", opts.ClosedBefore.UTC().Format(time.RFC3339))
+	}
+
+	sum := &ArchiveSummary{
+		DryRun:      opts.DryRun,
+		Tagged:      []string{},
+		NotExported: []string{},
+		Failed:      []TagFailure{},
+	}
+	p := s.paginate("orders_archive", "orders")
+	p.First = 250
+	p.Var("query", query)
+	var page []Order
+	for p.Next(ctx, &page) {
+		for _, o := range page {
+			// closed_at is not a documented orders filter, check the
+			// cutoff here too in case Shopify ignored it
+			if !opts.ClosedBefore.IsZero() && !o.ClosedAt.Before(opts.ClosedBefore) {
+				continue
+			}
+			if !o.HasTag(opts.ExportedTag) {
+				sum.NotExported = append(sum.NotExported, o.ID)
+				continue
+			}
+			sum.Tagged = append(sum.Tagged, o.ID)
+		}
+	}
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	if opts.DryRun || len(sum.Tagged) == 0 {
+		return sum, nil
+	}
+
+	// tag only after paging, adding the tag changes what the query matches
+	failed, err := s.tagBatches(ctx, "tagsAdd", sum.Tagged, []string{opts.ArchivedTag})
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) > 0 {
+		bad := map[string]bool{}
+		for _, f := range failed {
+			bad[f.ID] = true
+		}
+		tagged := []string{}
+		for _, id := range sum.Tagged {
+			if !bad[id] {
+				tagged = append(tagged, id)
+			}
+		}
+		sum.Tagged = tagged
+		sum.Failed = failed
+		return sum, &TagError{Mutation: "tagsAdd", Failures: failed}
+	}
+	return sum, nil
+} // ./OrderClosedAddArchiveTag
+
+// HasTag reports whether o has tag. Shopify compares tags case-insensitively.
+func (o Order) HasTag(tag string) bool {
+	for _, t := range o.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+} // ./HasTag
@@ -0,0 +1,344 @@
+package shopify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupHeader is the first line of an inventory backup file. Every
+// upload and restore appends one line per quantity it replaced.
+var backupHeader = []string{"run_id", "time", "sku", "inventory_item_id", "location_id", "previous", "new"}
+
+// BackupEntry is one replaced quantity in an inventory backup file.
+type BackupEntry struct {
+	RunID      string
+	Time       time.Time
+	Sku        string
+	ItemID     string
+	LocationID string
+	Previous   int
+	New        int
+}
+
+// newRunID names one upload or restore, e.g. 20230301T180000Z-1a2b3c4d.
+func newRunID() string {
+	now := time.Now().UTC()
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		// no randomness, the PID and nanoseconds still tell runs apart
+		return now.Format("20060102T150405Z") + fmt.Sprintf("-%08x", uint32(os.Getpid())<<16^uint32(now.Nanosecond()))
+	}
+	return now.Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+} // ./newRunID
+
+type backupWriter struct {
+	f     *os.File
+	w     *csv.Writer
+	runID string
+}
+
+// openBackup appends to the backup file at path, starting it with a
+// header when it is new.
+func openBackup(path, runID string) (*backupWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	bw := &backupWriter{f: f, w: csv.NewWriter(f), runID: runID}
+	if st.Size() == 0 {
+		bw.w.Write(backupHeader)
+	}
+	return bw, nil
+} // ./openBackup
+
+// write records the changes of a batch about to be sent and syncs them,
+// so a crash mid-send leaves the backup ahead of the stock, never behind.
+// A batch sent again after refreshLevels is written again; the last line
+// of an item and location in a run is the one that counts.
+func (bw *backupWriter) write(changes []*quantityChange) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, c := range changes {
+		bw.w.Write([]string{
+			bw.runID,
+			now,
+			c.item.Sku,
+			c.item.ID,
+			c.item.InventoryLevel.Location.ID,
+			strconv.Itoa(c.item.InventoryLevel.Available),
+			strconv.Itoa(c.quantity),
+		})
+	}
+	bw.w.Flush()
+	err := bw.w.Error()
+	if err == nil {
+		err = bw.f.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("backup %s: %w", bw.f.Name(), err)
+	}
+	return nil
+} // ./write
+
+func (bw *backupWriter) Close() error {
+	return bw.f.Close()
+} // ./Close
+
+// ReadInventoryBackup reads every entry of a backup file. Lines from
+// before backups carried a run ID are skipped.
+func ReadInventoryBackup(path string) ([]BackupEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	ee := []BackupEntry{}
+	for line := 1; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(row) != len(backupHeader) || row[0] == backupHeader[0] {
+			continue
+		}
+		e := BackupEntry{
+			RunID:      row[0],
+			Sku:        row[2],
+			ItemID:     row[3],
+			LocationID: row[4],
+		}
+		e.Time, err = time.Parse(time.RFC3339, row[1])
+		if err == nil {
+			e.Previous, err = strconv.Atoi(row[5])
+		}
+		if err == nil {
+			e.New, err = strconv.Atoi(row[6])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ee = append(ee, e)
+	}
+	return ee, nil
+} // ./ReadInventoryBackup
+
+type RestoreInventoryOptions struct {
+	// Backup defaults to "shopify_backup.csv".
+	Backup string
+	// RunID picks the upload to undo. Required.
+	RunID string
+	// Force restores even where stock moved since the upload.
+	Force bool
+	// DryRun only reports what would be restored.
+	DryRun bool
+}
+
+// ErrStockMoved is returned by RestoreInventory, without changing
+// anything, when quantities changed after the upload being undone.
+var ErrStockMoved = errors.New("stock moved since the upload")
+
+// RestoreInventory puts back the quantities an upload replaced. The
+// restore is itself backed up under the Service's run ID, as on its log
+// lines, so it can be undone too.
+func (s Service) RestoreInventory(ctx context.Context, opts RestoreInventoryOptions) (*InventoryReport, error) {
+	if opts.Backup == "" {
+		opts.Backup = "shopify_backup.csv"
+	}
+	if opts.RunID == "" {
+		return nil, fmt.Errorf("no run ID to restore")
+	}
+	entries, err := ReadInventoryBackup(opts.Backup)
+	if err != nil {
+		return nil, err
+	}
+
+	runID := s.runID
+	if runID == opts.RunID {
+		// restoring the Service's own upload, its lines must stay apart
+		runID = newRunID()
+	}
+	rep := &InventoryReport{DryRun: opts.DryRun, RunID: runID, Diffs: []InventoryDiff{}}
+	changes := []*quantityChange{}
+	seen := map[string]*quantityChange{}
+	for _, e := range entries {
+		if e.RunID != opts.RunID {
+			continue
+		}
+		if c, ok := seen[e.ItemID+" "+e.LocationID]; ok {
+			// written again for a retry after a stale compare quantity
+			c.item.InventoryLevel.Available = e.New
+			c.quantity = e.Previous
+			rep.Diffs[c.row].Quantity = e.Previous
+			continue
+		}
+		c := &quantityChange{
+			item: InventoryItem{
+				ID:  e.ItemID,
+				Sku: e.Sku,
+				InventoryLevel: &InventoryLevel{
+					Location:  Location{ID: e.LocationID},
+					Available: e.New,
+				},
+			},
+			quantity: e.Previous,
+			row:      len(rep.Diffs),
+		}
+		seen[e.ItemID+" "+e.LocationID] = c
+		changes = append(changes, c)
+		rep.Diffs = append(rep.Diffs, InventoryDiff{Sku: e.Sku, Location: e.LocationID, Quantity: e.Previous})
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("run %s not found in %s", opts.RunID, opts.Backup)
+	}
+
+	moved := []string{}
+	for start := 0; start < len(changes); start += inventoryBatchSize {
+		end := start + inventoryBatchSize
+		if end > len(changes) {
+			end = len(changes)
+		}
+		batch := changes[start:end]
+		uploaded := []int{}
+		for _, c := range batch {
+			uploaded = append(uploaded, c.item.InventoryLevel.Available)
+		}
+		err = s.refreshLevels(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range batch {
+			d := &rep.Diffs[c.row]
+			d.Available = c.item.InventoryLevel.Available
+			d.Delta = c.quantity - d.Available
+			d.Action = ActionSet
+			switch {
+			case d.Delta == 0:
+				// including a line backed up for a mutation that never
+				// landed
+				d.Action = ActionUnchanged
+			case d.Available != uploaded[i]:
+				d.Reason = fmt.Sprintf("uploaded %d, now %d", uploaded[i], d.Available)
+				moved = append(moved, fmt.Sprintf("%s at %s: %s", c.item.Sku, c.item.InventoryLevel.Location.ID, d.Reason))
+				if !opts.Force {
+					d.Action = ActionSkip
+				}
+			}
+		}
+	}
+	if len(moved) > 0 && !opts.Force {
+		return rep, fmt.Errorf("%w: %s", ErrStockMoved, strings.Join(moved, "; "))
+	}
+	if opts.DryRun {
+		return rep, nil
+	}
+
+	bak, err := openBackup(opts.Backup, rep.RunID)
+	if err != nil {
+		return rep, err
+	}
+	defer bak.Close()
+	// stop reports the changes from i on as not sent, the batches before
+	// them are restored already
+	stop := func(i int, err error) (*InventoryReport, error) {
+		for _, c := range changes[i:] {
+			d := &rep.Diffs[c.row]
+			if d.Action != ActionSkip {
+				d.Action, d.Reason, d.Delta = ActionSkip, "not sent: "+err.Error(), 0
+			}
+		}
+		return rep, err
+	}
+	// a change found stale this often is left out with Force
+	const maxStale = 3
+	skipped := []string{}
+	for start := 0; start < len(changes); start += inventoryBatchSize {
+		end := start + inventoryBatchSize
+		if end > len(changes) {
+			end = len(changes)
+		}
+		batch := changes[start:end]
+		staleCount := map[*quantityChange]int{}
+		for len(batch) > 0 {
+			err = bak.write(batch)
+			if err != nil {
+				return stop(start, err)
+			}
+			stale, refused, err := s.setQuantities(ctx, batch)
+			if err != nil {
+				return stop(start, err)
+			}
+			for i, c := range batch {
+				if refused[i] != nil {
+					return stop(start, fmt.Errorf("sku %s: %w", c.item.Sku, refused[i]))
+				}
+			}
+			if len(stale) == 0 {
+				break
+			}
+			isStale := map[int]bool{}
+			for _, i := range stale {
+				isStale[i] = true
+			}
+			rest := []*quantityChange{}
+			for i, c := range batch {
+				if !isStale[i] {
+					rest = append(rest, c)
+					continue
+				}
+				staleCount[c]++
+				d := &rep.Diffs[c.row]
+				switch {
+				case !opts.Force:
+					d.Action, d.Reason, d.Delta = ActionSkip, "stock moved during the restore", 0
+				case staleCount[c] >= maxStale:
+					d.Action, d.Reason, d.Delta = ActionSkip, "quantity kept changing", 0
+				default:
+					rest = append(rest, c)
+					continue
+				}
+				skipped = append(skipped, c.item.Sku)
+				s.log.Log(LevelWarn, "sku skipped", "sku", c.item.Sku, "location", d.Location, "reason", d.Reason)
+			}
+			batch = rest
+			if !opts.Force || len(batch) == 0 {
+				// the others were not stale, send them as they are
+				continue
+			}
+			err = s.refreshLevels(ctx, batch)
+			if err != nil {
+				return stop(start, err)
+			}
+			for _, c := range batch {
+				d := &rep.Diffs[c.row]
+				d.Available = c.item.InventoryLevel.Available
+				d.Delta = c.quantity - d.Available
+				d.Action = ActionSet
+				if d.Delta == 0 {
+					d.Action = ActionUnchanged
+				}
+			}
+		}
+	}
+	if len(skipped) > 0 {
+		return rep, fmt.Errorf("%d quantities (%s) not restored: %w", len(skipped), strings.Join(skipped, ", "), ErrQuantityChanged)
+	}
+	return rep, nil
+} // ./RestoreInventory
@@ -0,0 +1,224 @@
+package shopify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// BulkOperation is the state of a bulkOperationRunQuery.
+type BulkOperation struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// ErrorCode is set when Status is FAILED.
+	ErrorCode   string `json:"errorCode"`
+	ObjectCount string `json:"objectCount"`
+	// URL is the JSONL result, empty when the query matched nothing.
+	URL            string `json:"url"`
+	PartialDataURL string `json:"partialDataUrl"`
+}
+
+// RunBulkQuery submits query as a bulk operation, waits for it to finish
+// and streams the result to fn, one top-level node at a time. Child rows
+// of nested connections are put back under their parent as
+// {"nodes": [...]}, so fn can unmarshal into the same structs as a paged
+// query.
+func (s Service) RunBulkQuery(ctx context.Context, query string, fn func(node json.RawMessage) error) error {
+	rq := newRequest("bulk_operation_run_query")
+	rq.Var("query", query)
+	type response struct {
+		BulkOperationRunQuery struct {
+			BulkOperation BulkOperation `json:"bulkOperation"`
+			UserErrors    []UserErrors  `json:"userErrors"`
+		} `json:"bulkOperationRunQuery"`
+	}
+	var rs response
+	err := s.run(ctx, rq, &rs)
+	if err != nil {
+		return err
+	}
+	err = checkUserErrors("bulkOperationRunQuery", "", rs.BulkOperationRunQuery.UserErrors)
+	if err != nil {
+		return err
+	}
+	op, err := s.waitBulkOperation(ctx, rs.BulkOperationRunQuery.BulkOperation.ID)
+	if err != nil {
+		return err
+	}
+	s.log.Log(LevelInfo, "bulk operation completed", "bulk_id", op.ID, "objects", op.ObjectCount)
+	if op.URL == "" {
+		return nil
+	}
+
+	hrq, err := http.NewRequestWithContext(ctx, http.MethodGet, op.URL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.download.Do(hrq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("bulk operation %s: download: %s", op.ID, res.Status)
+	}
+	a := bulkAssembler{emit: fn}
+	sc := bufio.NewScanner(res.Body)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		err = a.add(sc.Bytes())
+		if err != nil {
+			return fmt.Errorf("bulk operation %s: %w", op.ID, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return a.flush()
+} // ./RunBulkQuery
+
+// waitBulkOperation polls currentBulkOperation until the operation id is
+// done.
+func (s Service) waitBulkOperation(ctx context.Context, id string) (*BulkOperation, error) {
+	type response struct {
+		CurrentBulkOperation *BulkOperation `json:"currentBulkOperation"`
+	}
+	for {
+		var rs response
+		err := s.run(ctx, newRequest("current_bulk_operation"), &rs)
+		if err != nil {
+			return nil, err
+		}
+		op := rs.CurrentBulkOperation
+		if op == nil || op.ID != id {
+			return nil, fmt.Errorf("bulk operation %s is no longer the current one", id)
+		}
+		switch op.Status {
+		case "COMPLETED":
+			return op, nil
+		case "FAILED", "CANCELED", "CANCELING", "EXPIRED":
+			return nil, fmt.Errorf("bulk operation %s %s %s", id, strings.ToLower(op.Status), op.ErrorCode)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.bulkPoll):
+		}
+	}
+} // ./waitBulkOperation
+
+// bulkQuery is the document queries/<name>.graphql with its variables
+// written in as literals, since a bulk query takes none.
+func bulkQuery(name string, vars map[string]interface{}) string {
+	doc, err := queryFiles.ReadFile("queries/" + name + ".graphql")
+	if err != nil {
+		panic(err)
+	}
+	q := string(doc[bytes.IndexByte(doc, '{'):])
+	for k, v := range vars {
+		lit, _ := json.Marshal(v)
+		q = regexp.MustCompile(`\$`+k+`\b`).ReplaceAllLiteralString(q, string(lit))
+	}
+	return q
+} // ./bulkQuery
+
+// bulkAssembler rebuilds nodes from the lines of a bulk result. A line
+// with __parentId is a node of a nested connection; it is appended to the
+// parent's connection named after its type, e.g. a LineItem to lineItems.
+// Shopify writes children right after their top-level node, so each
+// top-level node is emitted once the next one starts.
+type bulkAssembler struct {
+	root  map[string]interface{}
+	index map[string]map[string]interface{}
+	emit  func(json.RawMessage) error
+}
+
+func (a *bulkAssembler) add(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var n map[string]interface{}
+	err := dec.Decode(&n)
+	if err != nil {
+		return err
+	}
+	parentID, _ := n["__parentId"].(string)
+	if parentID == "" {
+		err = a.flush()
+		if err != nil {
+			return err
+		}
+		a.root = n
+		a.index = map[string]map[string]interface{}{}
+		indexNodes(a.index, n)
+		return nil
+	}
+	delete(n, "__parentId")
+	parent := a.index[parentID]
+	if parent == nil {
+		return fmt.Errorf("%v: parent %s not found", n["id"], parentID)
+	}
+	id, _ := n["id"].(string)
+	field := connectionField(id)
+	if field == "" {
+		return fmt.Errorf("child of %s without an id", parentID)
+	}
+	conn, _ := parent[field].(map[string]interface{})
+	if conn == nil {
+		conn = map[string]interface{}{"nodes": []interface{}{}}
+		parent[field] = conn
+	}
+	nodes, _ := conn["nodes"].([]interface{})
+	conn["nodes"] = append(nodes, n)
+	indexNodes(a.index, n)
+	return nil
+} // ./add
+
+func (a *bulkAssembler) flush() error {
+	if a.root == nil {
+		return nil
+	}
+	data, err := json.Marshal(a.root)
+	if err != nil {
+		return err
+	}
+	a.root = nil
+	return a.emit(data)
+} // ./flush
+
+// indexNodes records every object with an id in v, so children can find
+// parents nested in lists such as an order's fulfillments.
+func indexNodes(index map[string]map[string]interface{}, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if id, ok := v["id"].(string); ok {
+			index[id] = v
+		}
+		for _, c := range v {
+			indexNodes(index, c)
+		}
+	case []interface{}:
+		for _, c := range v {
+			indexNodes(index, c)
+		}
+	}
+} // ./indexNodes
+
+// connectionField names the connection a node of gid's type sits in,
+// e.g. gid://shopify/InventoryLevel/1?inventory_item_id=2 in
+// inventoryLevels.
+func connectionField(gid string) string {
+	parts := strings.Split(strings.TrimPrefix(gid, "gid://shopify/"), "/")
+	if len(parts) < 2 || parts[0] == "" {
+		return ""
+	}
+	return strings.ToLower(parts[0][:1]) + parts[0][1:] + "s"
+} // ./connectionField
@@ -0,0 +1,197 @@
+package shopify
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"atlasbilliards.com/pkg/solomon"
+)
+
+// exportSet stages the files of one export run as temp files in the
+// output directory and only renames them into place once every file has
+// been written, so Solomon never picks up a half written export. The
+// manifest is renamed last.
+type exportSet struct {
+	dir      string
+	manifest string
+	files    []*exportFile
+	done     bool
+}
+
+type exportFile struct {
+	name string
+	tmp  *os.File
+	sum  hash.Hash
+	w    recordWriter
+	rows int
+}
+
+// recordWriter is satisfied by *csv.Writer and *solomon.Writer.
+type recordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+type Manifest struct {
+	CompletedAt time.Time       `json:"completedAt"`
+	Files       []ManifestEntry `json:"files"`
+}
+
+type ManifestEntry struct {
+	Name   string `json:"name"`
+	Rows   int    `json:"rows"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+func newExportSet(dir, manifest string) (*exportSet, error) {
+	if dir == "" {
+		dir = "."
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &exportSet{dir: dir, manifest: manifest}, nil
+} // ./newExportSet
+
+// create stages a plain delimited file.
+func (es *exportSet) create(name string, comma rune) (*exportFile, error) {
+	return es.stage(name, func(w io.Writer) recordWriter {
+		cw := csv.NewWriter(w)
+		cw.Comma = comma
+		return cw
+	})
+} // ./create
+
+// createSolomon stages a file in Solomon's import format.
+func (es *exportSet) createSolomon(name string) (*exportFile, error) {
+	return es.stage(name, func(w io.Writer) recordWriter {
+		return solomon.NewWriter(w)
+	})
+} // ./createSolomon
+
+func (es *exportSet) stage(name string, newWriter func(io.Writer) recordWriter) (*exportFile, error) {
+	tmp, err := os.CreateTemp(es.dir, "."+name+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp makes the file 0600, other users need to read exports too
+	err = tmp.Chmod(0644)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	ef := &exportFile{
+		name: name,
+		tmp:  tmp,
+		sum:  sha256.New(),
+	}
+	ef.w = newWriter(io.MultiWriter(tmp, ef.sum))
+	es.files = append(es.files, ef)
+	return ef, nil
+} // ./stage
+
+// commit flushes and renames every staged file into place, then writes
+// the manifest.
+func (es *exportSet) commit() (*Manifest, error) {
+	m := &Manifest{Files: []ManifestEntry{}}
+	for _, ef := range es.files {
+		ef.w.Flush()
+		err := ef.w.Error()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ef.name, err)
+		}
+		err = ef.tmp.Sync()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ef.name, err)
+		}
+		st, err := ef.tmp.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ef.name, err)
+		}
+		m.Files = append(m.Files, ManifestEntry{
+			Name:   ef.name,
+			Rows:   ef.rows,
+			Bytes:  st.Size(),
+			SHA256: hex.EncodeToString(ef.sum.Sum(nil)),
+		})
+	}
+	m.CompletedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	mtmp, err := os.CreateTemp(es.dir, "."+es.manifest+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	err = mtmp.Chmod(0644)
+	if err == nil {
+		_, err = mtmp.Write(append(data, '\n'))
+	}
+	if err == nil {
+		err = mtmp.Close()
+	}
+	if err != nil {
+		os.Remove(mtmp.Name())
+		return nil, err
+	}
+
+	for _, ef := range es.files {
+		err = ef.tmp.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ef.name, err)
+		}
+		err = os.Rename(ef.tmp.Name(), filepath.Join(es.dir, ef.name))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = os.Rename(mtmp.Name(), filepath.Join(es.dir, es.manifest))
+	if err != nil {
+		return nil, err
+	}
+	es.done = true
+	return m, nil
+} // ./commit
+
+// abort removes whatever has not been committed. Safe to defer.
+func (es *exportSet) abort() {
+	if es.done {
+		return
+	}
+	for _, ef := range es.files {
+		ef.tmp.Close()
+		os.Remove(ef.tmp.Name())
+	}
+	es.done = true
+} // ./abort
+
+// WriteHeader writes a row that is not counted in the manifest.
+func (ef *exportFile) WriteHeader(record []string) error {
+	if sw, ok := ef.w.(*solomon.Writer); ok {
+		return sw.WriteHeader(record)
+	}
+	return ef.w.Write(record)
+} // ./WriteHeader
+
+func (ef *exportFile) Write(record []string) error {
+	err := ef.w.Write(record)
+	if err != nil {
+		return err
+	}
+	ef.rows++
+	return nil
+} // ./Write
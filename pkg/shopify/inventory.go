@@ -0,0 +1,274 @@
+package shopify
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/machinebox/graphql"
+)
+
+// inventoryBatchSize is how many quantities go into one
+// inventorySetQuantities call. Shopify accepts up to 250.
+const inventoryBatchSize = 100
+
+// allInventoryItems pages through every inventory item of the shop with
+// its levels at every location.
+func (s Service) allInventoryItems(ctx context.Context) ([]InventoryItem, error) {
+	ii := []InventoryItem{}
+	if s.bulk {
+		err := s.RunBulkQuery(ctx, bulkQuery("bulk_inventory_items", nil), func(node json.RawMessage) error {
+			var i InventoryItem
+			err := json.Unmarshal(node, &i)
+			i.apiMeta = s.apiMeta
+			ii = append(ii, i)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return ii, nil
+	}
+	p := s.paginate("inventory_items", "inventoryItems")
+	var page []json.RawMessage
+	for p.Next(ctx, &page) {
+		for _, node := range page {
+			var i InventoryItem
+			var levels struct {
+				InventoryLevels struct {
+					PageInfo PageInfo `json:"pageInfo"`
+				} `json:"inventoryLevels"`
+			}
+			err := json.Unmarshal(node, &i)
+			if err == nil {
+				err = json.Unmarshal(node, &levels)
+			}
+			if err == nil {
+				err = s.completeLevels(ctx, &i, levels.InventoryLevels.PageInfo)
+			}
+			if err != nil {
+				return nil, err
+			}
+			i.apiMeta = s.apiMeta
+			ii = append(ii, i)
+		}
+	}
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	return ii, nil
+} // ./allInventoryItems
+
+// completeLevels reads the inventory levels of i past the first page,
+// for an item stocked at more locations than inventory_items selects.
+func (s Service) completeLevels(ctx context.Context, i *InventoryItem, pi PageInfo) error {
+	if !pi.HasNextPage {
+		return nil
+	}
+	p := s.paginate("inventory_levels", "inventoryItem", "inventoryLevels")
+	p.First = 250
+	p.Var("id", i.ID).After(pi.EndCursor)
+	var page []InventoryLevel
+	for p.Next(ctx, &page) {
+		i.InventoryLevels = append(i.InventoryLevels, page...)
+	}
+	if err := p.Err(); err != nil {
+		return fmt.Errorf("inventory item %s: %w", i.ID, err)
+	}
+	return nil
+} // ./completeLevels
+
+// skuIndex maps the skuKey of each SKU to its inventory items, more than
+// one when the SKU is duplicated in Shopify.
+func skuIndex(ii []InventoryItem, loose bool) map[string][]InventoryItem {
+	idx := map[string][]InventoryItem{}
+	for _, i := range ii {
+		sku := skuKey(i.Sku, loose)
+		if sku == "" {
+			continue
+		}
+		idx[sku] = append(idx[sku], i)
+	}
+	return idx
+} // ./skuIndex
+
+// quantityChange sets item's available quantity at item.InventoryLevel,
+// comparing against item.InventoryLevel.Available. row is the change's
+// line in the InventoryReport.
+type quantityChange struct {
+	item     InventoryItem
+	quantity int
+	row      int
+}
+
+// setQuantities sends every change in one compare-and-set mutation.
+// Shopify applies all of them or none; when some are stale or refused
+// nothing is changed, and the indexes of the stale ones are returned
+// along with the userErrors of each refused one. Errors Shopify does not
+// tie to a change fail the whole call.
+func (m apiMeta) setQuantities(ctx context.Context, changes []*quantityChange) ([]int, map[int]*UserErrorsError, error) {
+	rq := newRequest("inventory_set_quantities")
+	qq := []map[string]interface{}{}
+	for _, c := range changes {
+		qq = append(qq, map[string]interface{}{
+			"inventoryItemId": c.item.ID,
+			"locationId":      c.item.InventoryLevel.Location.ID,
+			"quantity":        c.quantity,
+			"compareQuantity": c.item.InventoryLevel.Available,
+		})
+	}
+	rq.Var("input", map[string]interface{}{
+		"name":       "available",
+		"reason":     "correction",
+		"quantities": qq,
+	})
+	type response struct {
+		InventorySetQuantities struct {
+			UserErrors []UserErrors `json:"userErrors"`
+		} `json:"inventorySetQuantities"`
+	}
+	var rs response
+	err := m.run(ctx, rq, &rs)
+	if err != nil {
+		return nil, nil, err
+	}
+	stale := []int{}
+	refused := map[int]*UserErrorsError{}
+	untied := []UserErrors{}
+	for _, ue := range rs.InventorySetQuantities.UserErrors {
+		// field is ["input", "quantities", "<index>", ...]
+		i := -1
+		if len(ue.Field) > 2 {
+			i, _ = strconv.Atoi(ue.Field[2])
+		}
+		switch {
+		case i < 0 || i >= len(changes):
+			untied = append(untied, ue)
+		case ue.Code == "COMPARE_QUANTITY_STALE":
+			stale = append(stale, i)
+		case refused[i] == nil:
+			refused[i] = &UserErrorsError{Mutation: "inventorySetQuantities", ID: changes[i].item.ID, Errors: []UserErrors{ue}}
+		default:
+			refused[i].Errors = append(refused[i].Errors, ue)
+		}
+	}
+	if len(untied) > 0 {
+		return nil, nil, checkUserErrors("inventorySetQuantities", "", untied)
+	}
+	return stale, refused, nil
+} // ./setQuantities
+
+// refreshLevels reads the current available quantity of every change
+// again, with one batched query.
+func (s Service) refreshLevels(ctx context.Context, changes []*quantityChange) error {
+	rq := graphql.NewRequest(batchDocument("inventory_level", len(changes)))
+	for i, c := range changes {
+		rq.Var(batchVar("id", i), c.item.ID)
+		rq.Var(batchVar("locationId", i), c.item.InventoryLevel.Location.ID)
+	}
+	var rs map[string]*InventoryItem
+	err := s.run(ctx, rq, &rs)
+	if err != nil {
+		return err
+	}
+	for i, c := range changes {
+		r := rs[batchAlias(i)]
+		if r == nil || r.InventoryLevel == nil {
+			return fmt.Errorf("%s: inventory level at %s is gone", c.item.Sku, c.item.InventoryLevel.Location.Name)
+		}
+		c.item.InventoryLevel.Available = r.InventoryLevel.Available
+	}
+	return nil
+} // ./refreshLevels
+
+const (
+	ActionSet       = "set"
+	ActionUnchanged = "unchanged"
+	ActionSkip      = "skip"
+)
+
+// InventoryDiff is what UploadInventory does with one row of the Solomon
+// file.
+type InventoryDiff struct {
+	Sku      string
+	Location string
+	// Available is Shopify's quantity before the upload.
+	Available int
+	// Quantity is Solomon's.
+	Quantity int
+	Delta    int
+	// Action is ActionSet, ActionUnchanged or ActionSkip.
+	Action string
+	// Reason explains a skip, or notes a duplicate SKU.
+	Reason string
+}
+
+type InventoryReport struct {
+	DryRun bool
+	// RunID names the lines of this run in the backup file.
+	RunID string
+	Diffs []InventoryDiff
+}
+
+// WriteCSV replaces the file at path with one line per diff.
+func (r InventoryReport) WriteCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"sku", "location", "shopify_available", "solomon_quantity", "delta", "action", "reason"})
+	for _, d := range r.Diffs {
+		w.Write([]string{
+			d.Sku,
+			d.Location,
+			strconv.Itoa(d.Available),
+			strconv.Itoa(d.Quantity),
+			strconv.Itoa(d.Delta),
+			d.Action,
+			d.Reason,
+		})
+	}
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+} // ./WriteCSV
+
+// Summary is a few lines for a human to read before running for real.
+func (r InventoryReport) Summary() string {
+	var set, unchanged, skipped, up, down int
+	for _, d := range r.Diffs {
+		switch d.Action {
+		case ActionSet:
+			set++
+			if d.Delta > 0 {
+				up += d.Delta
+			} else {
+				down -= d.Delta
+			}
+		case ActionUnchanged:
+			unchanged++
+		case ActionSkip:
+			skipped++
+		}
+	}
+	verb := "changed"
+	if r.DryRun {
+		verb = "would change"
+	}
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%d rows: %s %d SKUs (+%d / -%d units), %d unchanged, %d skipped\n", len(r.Diffs), verb, set, up, down, unchanged, skipped)
+	for _, d := range r.Diffs {
+		if d.Action == ActionSkip {
+			fmt.Fprintf(&b, "  skipped %s at %s: %s\n", d.Sku, d.Location, d.Reason)
+		}
+	}
+	return b.String()
+} // ./Summary
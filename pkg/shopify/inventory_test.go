@@ -0,0 +1,109 @@
+package shopify
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetQuantitiesStale(t *testing.T) {
+	s, srv := newInventoryService(t)
+	ctx := context.Background()
+
+	items, err := s.allInventoryItems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := skuIndex(items, false)
+	changes := []*quantityChange{}
+	for _, sku := range []string{"CHALK-12", "CUE-1"} {
+		ii := idx[sku][0]
+		lvl := *ii.InventoryLevels.At(testLoc)
+		ii.InventoryLevel = &lvl
+		changes = append(changes, &quantityChange{item: ii, quantity: 20})
+	}
+
+	// a CUE-1 sold after the prefetch fails the whole batch
+	srv.SetQuantity(testItem, testLoc, "available", 4)
+	stale, refused, err := s.setQuantities(ctx, changes)
+	if err != nil || len(refused) != 0 {
+		t.Fatalf("refused %v, err %v", refused, err)
+	}
+	if !reflect.DeepEqual(stale, []int{1}) {
+		t.Fatalf("stale %v, want [1]", stale)
+	}
+	item, err := s.allInventoryItems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := skuIndex(item, false)["CHALK-12"][0].InventoryLevels.At(testLoc).Available; got != 40 {
+		t.Errorf("CHALK-12 changed to %d by a failed batch", got)
+	}
+
+	err = s.refreshLevels(ctx, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changes[1].item.InventoryLevel.Available; got != 4 {
+		t.Errorf("refreshed CUE-1 to %d, want 4", got)
+	}
+	stale, refused, err = s.setQuantities(ctx, changes)
+	if err != nil || len(stale) != 0 || len(refused) != 0 {
+		t.Fatalf("retry: stale %v, refused %v, err %v", stale, refused, err)
+	}
+} // ./TestSetQuantitiesStale
+
+func TestAllInventoryItemsBulk(t *testing.T) {
+	s, _ := newInventoryService(t)
+	ctx := context.Background()
+	paged, err := s.allInventoryItems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.bulk, s.bulkPoll = true, time.Millisecond
+	bulk, err := s.allInventoryItems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bulk, paged) {
+		t.Errorf("bulk items:\n%+v\npaged:\n%+v", bulk, paged)
+	}
+} // ./TestAllInventoryItemsBulk
+
+func TestAllInventoryItemsManyLevels(t *testing.T) {
+	s, srv := newInventoryService(t)
+	ctx := context.Background()
+	// more locations than the first page of levels holds
+	edges := []interface{}{}
+	for i := 0; i < 12; i++ {
+		edges = append(edges, map[string]interface{}{"node": map[string]interface{}{
+			"id":         fmt.Sprintf("gid://shopify/InventoryLevel/%d?inventory_item_id=6009", 9000+i),
+			"quantities": []interface{}{map[string]interface{}{"name": "available", "quantity": i}},
+			"location":   map[string]interface{}{"id": fmt.Sprintf("gid://shopify/Location/%d", 100+i), "name": fmt.Sprintf("Store %d", i)},
+		}})
+	}
+	srv.Add("inventoryItems", map[string]interface{}{
+		"id":              "gid://shopify/InventoryItem/6009",
+		"sku":             "BALLS-9",
+		"inventoryLevels": map[string]interface{}{"edges": edges},
+	})
+
+	paged, err := s.allInventoryItems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels := skuIndex(paged, false)["BALLS-9"][0].InventoryLevels
+	if len(levels) != 12 || levels.At("gid://shopify/Location/111").Available != 11 {
+		t.Fatalf("levels: %+v", levels)
+	}
+	s.bulk, s.bulkPoll = true, time.Millisecond
+	bulk, err := s.allInventoryItems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bulk, paged) {
+		t.Errorf("bulk items:\n%+v\npaged:\n%+v", bulk, paged)
+	}
+} // ./TestAllInventoryItemsManyLevels
@@ -0,0 +1,104 @@
+package shopify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type Location struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type locationCache struct {
+	mu        sync.Mutex
+	wanted    []string
+	locations []Location
+}
+
+// Locations returns the locations configured through Config.Locations, in
+// the configured order, or every active location of the shop when none
+// were configured. Names are resolved once per Service; a failed lookup,
+// e.g. a canceled ctx, is tried again on the next call.
+func (s Service) Locations(ctx context.Context) ([]Location, error) {
+	s.locs.mu.Lock()
+	defer s.locs.mu.Unlock()
+	if s.locs.locations != nil {
+		return s.locs.locations, nil
+	}
+	ll, err := s.resolveLocations(ctx, s.locs.wanted)
+	if err != nil {
+		return nil, err
+	}
+	s.locs.locations = ll
+	return ll, nil
+} // ./Locations
+
+// Location looks up a location by ID or (case-insensitive) name. An empty
+// ref returns the default location: the first configured one, or the only
+// one the shop has.
+func (s Service) Location(ctx context.Context, ref string) (Location, error) {
+	ll, err := s.Locations(ctx)
+	if err != nil {
+		return Location{}, err
+	}
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		if len(s.locs.wanted) == 0 && len(ll) > 1 {
+			return Location{}, fmt.Errorf("shop has %d locations, configure one or pass it explicitly", len(ll))
+		}
+		if len(ll) == 0 {
+			return Location{}, fmt.Errorf("no locations available")
+		}
+		return ll[0], nil
+	}
+	for _, l := range ll {
+		if l.ID == ref || strings.EqualFold(l.Name, ref) {
+			return l, nil
+		}
+	}
+	return Location{}, fmt.Errorf("unknown location %q", ref)
+} // ./Location
+
+func (s Service) resolveLocations(ctx context.Context, wanted []string) ([]Location, error) {
+	rq := newRequest("locations")
+	rq.Var("first", 250)
+	type response struct {
+		Locations struct {
+			Edges []struct {
+				Node Location `json:"node"`
+			} `json:"edges"`
+		} `json:"locations"`
+	}
+	var rs response
+	err := s.run(ctx, rq, &rs)
+	if err != nil {
+		return nil, err
+	}
+	all := []Location{}
+	for _, e := range rs.Locations.Edges {
+		all = append(all, e.Node)
+	}
+	if len(wanted) == 0 {
+		return all, nil
+	}
+
+	ll := []Location{}
+	for _, w := range wanted {
+		w = strings.TrimSpace(w)
+		found := false
+		for _, l := range all {
+			if l.ID == w || strings.EqualFold(l.Name, w) {
+				ll = append(ll, l)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("location %q not found in shop", w)
+		}
+	}
+	return ll, nil
+} // ./resolveLocations
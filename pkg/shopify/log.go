@@ -0,0 +1,114 @@
+package shopify
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level orders log records. The values match log/slog so a Logger can
+// hand them straight to a slog handler.
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	}
+	return "ERROR"
+} // ./String
+
+// Logger receives the Service's log records. fields are key, value pairs
+// such as "sku", "CUE-1", "delta", 3.
+type Logger interface {
+	Log(level Level, msg string, fields ...interface{})
+}
+
+// NewTextLogger writes one logfmt line per record of at least min to w:
+//
+//	time=2024-05-01T02:00:00Z level=INFO msg="quantity set" run_id=... sku=CUE-1 delta=3
+func NewTextLogger(w io.Writer, min Level) Logger {
+	return &textLogger{w: w, min: min}
+} // ./NewTextLogger
+
+type textLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	min Level
+}
+
+func (l *textLogger) Log(level Level, msg string, fields ...interface{}) {
+	if level < l.min {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(time.Now().UTC().Format(time.RFC3339))
+	b.WriteString(" level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(logfmtValue(msg))
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		var v interface{} = "!MISSING"
+		if i+1 < len(fields) {
+			v = fields[i+1]
+		}
+		b.WriteByte(' ')
+		b.WriteString(key)
+		b.WriteByte('=')
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		b.WriteString(logfmtValue(fmt.Sprint(v)))
+	}
+	b.WriteByte('\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, b.String())
+} // ./Log
+
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=\\") {
+		return strconv.Quote(s)
+	}
+	return s
+} // ./logfmtValue
+
+// WithFields returns a Logger that adds fields to every record of l.
+func WithFields(l Logger, fields ...interface{}) Logger {
+	if len(fields) == 0 {
+		return l
+	}
+	if fl, ok := l.(fieldLogger); ok {
+		return fieldLogger{l: fl.l, fields: append(append([]interface{}{}, fl.fields...), fields...)}
+	}
+	return fieldLogger{l: l, fields: fields}
+} // ./WithFields
+
+type fieldLogger struct {
+	l      Logger
+	fields []interface{}
+}
+
+func (l fieldLogger) Log(level Level, msg string, fields ...interface{}) {
+	l.l.Log(level, msg, append(append([]interface{}{}, l.fields...), fields...)...)
+} // ./Log
+
+type nopLogger struct{}
+
+func (nopLogger) Log(Level, string, ...interface{}) {}
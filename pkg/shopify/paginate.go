@@ -0,0 +1,159 @@
+package shopify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/machinebox/graphql"
+)
+
+// PageInfo is the pageInfo of a connection.
+type PageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// Paginator walks any connection page by page, e.g.
+//
+//	p := s.NewPaginator(query, "order", "lineItems").Var("id", id)
+//	var page []LineItem
+//	for p.Next(ctx, &page) {
+//		...
+//	}
+//	err := p.Err()
+//
+// The query takes the page size as $first and the cursor as $after and
+// selects the connection's nodes or edges { node }, and
+// pageInfo { hasNextPage endCursor }. The connection is found in the
+// response by following path, so it can be nested under singular fields.
+type Paginator struct {
+	s    Service
+	doc  string
+	path []string
+	vars map[string]interface{}
+	// First is the page size. Defaults to 50.
+	First int
+	// Pages and Nodes count what was read so far.
+	Pages int
+	Nodes int
+	// OnPage is called after every page, e.g. to show progress.
+	OnPage func(p *Paginator)
+
+	after string
+	done  bool
+	err   error
+}
+
+// NewPaginator pages through the connection at path in the response to
+// query.
+func (s Service) NewPaginator(query string, path ...string) *Paginator {
+	return &Paginator{s: s, doc: query, path: path, vars: map[string]interface{}{}, First: 50}
+} // ./NewPaginator
+
+// paginate is NewPaginator with the document in queries/<name>.graphql.
+func (s Service) paginate(name string, path ...string) *Paginator {
+	doc, err := queryFiles.ReadFile("queries/" + name + ".graphql")
+	if err != nil {
+		panic(err)
+	}
+	return s.NewPaginator(string(doc), path...)
+} // ./paginate
+
+// Var sets a variable sent with every page.
+func (p *Paginator) Var(key string, value interface{}) *Paginator {
+	p.vars[key] = value
+	return p
+} // ./Var
+
+// After starts the paginator after cursor, e.g. where a nested connection
+// of an earlier query stopped.
+func (p *Paginator) After(cursor string) *Paginator {
+	p.after = cursor
+	return p
+} // ./After
+
+// Next reads the next page into nodes, a pointer to a slice, replacing its
+// contents. It returns false once the last page was read, the connection
+// is null, ctx is done or a request failed, see Err.
+func (p *Paginator) Next(ctx context.Context, nodes interface{}) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		p.err = err
+		return false
+	}
+	rq := graphql.NewRequest(p.doc)
+	for k, v := range p.vars {
+		rq.Var(k, v)
+	}
+	rq.Var("first", p.First)
+	rq.Var("after", optional(p.after))
+	var rs json.RawMessage
+	err := p.s.run(ctx, rq, &rs)
+	if err != nil {
+		p.err = fmt.Errorf("%s page %d: %w", strings.Join(p.path, "."), p.Pages+1, err)
+		return false
+	}
+	for _, key := range p.path {
+		var m map[string]json.RawMessage
+		err = json.Unmarshal(rs, &m)
+		if err != nil {
+			p.err = fmt.Errorf("%s: %w", strings.Join(p.path, "."), err)
+			return false
+		}
+		rs = m[key]
+		if len(rs) == 0 || bytes.Equal(rs, []byte("null")) {
+			p.done = true
+			return false
+		}
+	}
+	var conn struct {
+		Edges []struct {
+			Node json.RawMessage `json:"node"`
+		} `json:"edges"`
+		Nodes    []json.RawMessage `json:"nodes"`
+		PageInfo PageInfo          `json:"pageInfo"`
+	}
+	err = json.Unmarshal(rs, &conn)
+	if err != nil {
+		p.err = fmt.Errorf("%s: %w", strings.Join(p.path, "."), err)
+		return false
+	}
+	raw := conn.Nodes
+	if len(raw) == 0 {
+		for _, e := range conn.Edges {
+			raw = append(raw, e.Node)
+		}
+	}
+	// decode into a fresh slice, json would reuse the old elements
+	v := reflect.ValueOf(nodes).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	list, _ := json.Marshal(raw)
+	err = json.Unmarshal(list, nodes)
+	if err != nil {
+		p.err = fmt.Errorf("%s: %w", strings.Join(p.path, "."), err)
+		return false
+	}
+
+	p.Pages++
+	p.Nodes += len(raw)
+	p.after = conn.PageInfo.EndCursor
+	p.done = !conn.PageInfo.HasNextPage
+	if !p.done && p.after == "" {
+		p.err = fmt.Errorf("%s: next page without an endCursor", strings.Join(p.path, "."))
+	}
+	if p.OnPage != nil {
+		p.OnPage(p)
+	}
+	return true
+} // ./Next
+
+// Err is the error that stopped Next, if any.
+func (p *Paginator) Err() error {
+	return p.err
+} // ./Err
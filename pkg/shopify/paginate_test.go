@@ -0,0 +1,53 @@
+package shopify
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPaginator(t *testing.T) {
+	s, srv := newInventoryService(t)
+
+	p := s.paginate("inventory_items", "inventoryItems")
+	p.First = 2
+	progress := []int{}
+	p.OnPage = func(p *Paginator) { progress = append(progress, p.Nodes) }
+	skus := []string{}
+	var page []InventoryItem
+	for p.Next(context.Background(), &page) {
+		for _, i := range page {
+			skus = append(skus, i.Sku)
+		}
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(skus) != 3 || p.Pages != 2 || len(progress) != 2 || progress[1] != 3 {
+		t.Errorf("read %v in %d pages, progress %v", skus, p.Pages, progress)
+	}
+
+	// an empty connection is one empty page, not an endless loop
+	p = s.paginate("orders_archive", "orders").Var("query", "")
+	pages := 0
+	var orders []Order
+	for p.Next(context.Background(), &orders) {
+		pages++
+		if pages > 1 || len(orders) != 0 {
+			t.Fatalf("page %d of nothing: %v", pages, orders)
+		}
+	}
+	if p.Err() != nil || pages != 1 {
+		t.Errorf("%d pages, err %v", pages, p.Err())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	before := srv.Requests()
+	p = s.paginate("inventory_items", "inventoryItems")
+	if p.Next(ctx, &page) || p.Err() != context.Canceled {
+		t.Errorf("cancelled paginator: %v", p.Err())
+	}
+	if srv.Requests() != before {
+		t.Error("cancelled paginator sent a request")
+	}
+} // ./TestPaginator
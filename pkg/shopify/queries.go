@@ -0,0 +1,98 @@
+package shopify
+
+import (
+	"embed"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/machinebox/graphql"
+)
+
+// queryFiles holds the GraphQL documents the Service sends. Values are
+// always passed as variables, never spliced into a document.
+//
+//go:embed queries/*.graphql
+var queryFiles embed.FS
+
+// newRequest starts a request with the document in queries/<name>.graphql.
+func newRequest(name string) *graphql.Request {
+	doc, err := queryFiles.ReadFile("queries/" + name + ".graphql")
+	if err != nil {
+		panic(err)
+	}
+	return graphql.NewRequest(string(doc))
+} // ./newRequest
+
+// optional is v as a variable value, null when empty, e.g. the after
+// cursor of the first page.
+func optional(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+} // ./optional
+
+var (
+	operationHeader = regexp.MustCompile(`^(query|mutation) (\w+)\(([^)]*)\) \{`)
+	variableRef     = regexp.MustCompile(`\$(\w+)\b`)
+)
+
+// batchDocument repeats the root field of queries/<name>.graphql n times
+// in one operation, aliased batchAlias(0) to batchAlias(n-1). Variables
+// not in shared are declared once per copy as batchVar(name, i), e.g.
+// $id0 and $id1; shared ones are sent once for all copies.
+func batchDocument(name string, n int, shared ...string) string {
+	data, err := queryFiles.ReadFile("queries/" + name + ".graphql")
+	if err != nil {
+		panic(err)
+	}
+	doc := string(data)
+	m := operationHeader.FindStringSubmatch(doc)
+	if m == nil {
+		panic(name + ": not a named operation with variables")
+	}
+	body := strings.TrimSpace(doc[len(m[0]):strings.LastIndex(doc, "}")])
+	isShared := map[string]bool{}
+	for _, v := range shared {
+		isShared[v] = true
+	}
+
+	params := []string{}
+	perCopy := []string{}
+	for _, p := range strings.Split(m[3], ",") {
+		p = strings.TrimSpace(p)
+		v := variableRef.FindStringSubmatch(p)[1]
+		if isShared[v] {
+			params = append(params, p)
+			continue
+		}
+		perCopy = append(perCopy, p)
+	}
+	rename := func(s string, i int) string {
+		return variableRef.ReplaceAllStringFunc(s, func(ref string) string {
+			if isShared[ref[1:]] {
+				return ref
+			}
+			return "$" + batchVar(ref[1:], i)
+		})
+	}
+	fields := []string{}
+	for i := 0; i < n; i++ {
+		for _, p := range perCopy {
+			params = append(params, rename(p, i))
+		}
+		fields = append(fields, "  "+batchAlias(i)+": "+rename(body, i))
+	}
+	return fmt.Sprintf("%s %sBatch(%s) {\n%s\n}\n", m[1], m[2], strings.Join(params, ", "), strings.Join(fields, "\n"))
+} // ./batchDocument
+
+// batchAlias is the alias of copy i in a batchDocument.
+func batchAlias(i int) string {
+	return fmt.Sprintf("b%d", i)
+} // ./batchAlias
+
+// batchVar is the variable name of copy i in a batchDocument.
+func batchVar(name string, i int) string {
+	return fmt.Sprintf("%s%d", name, i)
+} // ./batchVar
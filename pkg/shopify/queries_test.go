@@ -0,0 +1,109 @@
+package shopify
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var (
+	operationRe = regexp.MustCompile(`^(query|mutation) \w+(\(([^)]*)\))? \{`)
+	declaredRe  = regexp.MustCompile(`\$(\w+):`)
+	usedRe      = regexp.MustCompile(`:\s*\$(\w+)`)
+)
+
+// TestQueryDocuments checks every embedded document is a named operation
+// that declares exactly the variables it uses. There is no Admin API
+// schema in the tree to check fields against; the fake server in
+// shopifytest runs each document in the service tests instead.
+func TestQueryDocuments(t *testing.T) {
+	names, err := fs.Glob(queryFiles, "queries/*.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no embedded documents")
+	}
+	for _, name := range names {
+		data, _ := queryFiles.ReadFile(name)
+		checkDocument(t, name, string(data))
+	}
+} // ./TestQueryDocuments
+
+// checkDocument reports doc unless it is a named operation that declares
+// exactly the variables it uses.
+func checkDocument(t *testing.T, name, doc string) {
+	t.Helper()
+	m := operationRe.FindStringSubmatch(doc)
+	if m == nil {
+		t.Errorf("%s: not a named query or mutation", name)
+		return
+	}
+	if strings.Count(doc, "{") != strings.Count(doc, "}") || strings.Count(doc, "(") != strings.Count(doc, ")") {
+		t.Errorf("%s: unbalanced braces", name)
+	}
+	if strings.Contains(doc, "%") {
+		t.Errorf("%s: has a format verb", name)
+	}
+	declared := matchSet(declaredRe, m[3])
+	used := matchSet(usedRe, doc[len(m[0]):])
+	if strings.Join(declared, ",") != strings.Join(used, ",") {
+		t.Errorf("%s: declares %v, uses %v", name, declared, used)
+	}
+} // ./checkDocument
+
+func TestBatchDocument(t *testing.T) {
+	want := `mutation tagsAddBatch($tags: [String!]!, $id0: ID!, $id1: ID!) {
+  b0: tagsAdd(id: $id0, tags: $tags) {
+    node {
+      id
+    }
+    userErrors {
+      field
+      message
+    }
+  }
+  b1: tagsAdd(id: $id1, tags: $tags) {
+    node {
+      id
+    }
+    userErrors {
+      field
+      message
+    }
+  }
+}
+`
+	if got := batchDocument("tags_add", 2, "tags"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	// every document that is sent batched
+	for _, c := range []struct {
+		name   string
+		shared []string
+	}{
+		{"inventory_level", nil},
+		{"tags_add", []string{"tags"}},
+		{"tags_remove", []string{"tags"}},
+	} {
+		for _, n := range []int{1, 3} {
+			checkDocument(t, fmt.Sprintf("%s x%d", c.name, n), batchDocument(c.name, n, c.shared...))
+		}
+	}
+} // ./TestBatchDocument
+
+func matchSet(re *regexp.Regexp, s string) []string {
+	set := map[string]bool{}
+	for _, m := range re.FindAllStringSubmatch(s, -1) {
+		set[m[1]] = true
+	}
+	out := []string{}
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+} // ./matchSet
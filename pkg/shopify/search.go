@@ -0,0 +1,14 @@
+package shopify
+
+import "strings"
+
+// searchEscaper escapes the characters that end or split a quoted value
+// in Shopify's search syntax.
+var searchEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// SearchValue quotes v so a search term matches it literally, e.g.
+// "sku:" + SearchValue(`CUE 1"`) is sku:"CUE 1\"". Quotes, spaces and
+// colons in v no longer end the term or start another one.
+func SearchValue(v string) string {
+	return `"` + searchEscaper.Replace(v) + `"`
+} // ./SearchValue
@@ -3,163 +3,202 @@ package shopify
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net/http"
 	"os"
-	"os/exec"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
-	"atlasbilliards.com/pkg/date"
+	"atlasbilliards.com/pkg/solomon"
 	"github.com/machinebox/graphql"
 )
 
+const DefaultAPIVersion = "2024-04"
+
 type apiMeta struct {
 	accessToken string
 	shop        string
 	endpoint    string
-	locationID  string
+	locs        *locationCache
+	gql         *graphql.Client
+	log         Logger
+	timeout     time.Duration
 }
 
+func (m apiMeta) run(ctx context.Context, rq *graphql.Request, resp interface{}) error {
+	rq.Header.Set("X-Shopify-Access-Token", m.accessToken)
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	return m.gql.Run(ctx, rq, resp)
+} // ./run
+
 type Service struct {
 	apiMeta
+	logger    Logger
+	runID     string
+	outputDir string
+	// download fetches bulk results, which are plain files and cost
+	// nothing, so it skips the Transport.
+	download *http.Client
+	bulk     bool
+	bulkPoll time.Duration
 }
 
 type Config struct {
-	Shop        string
+	Shop string
+	// AccessToken is an Admin API access token, shpat_, shpca_ or shppa_
+	// followed by 32 hex digits. Other tokens are refused before anything
+	// is sent.
 	AccessToken string
+	// APIVersion defaults to DefaultAPIVersion.
+	APIVersion string
+	// BaseURL replaces https://<shop>.myshopify.com, e.g. to point at a
+	// local fake server.
+	BaseURL string
+	// HTTPClient is wrapped with the retrying Transport. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// Locations are the inventory locations (IDs or names) to work with,
+	// the first one being the default for uploads. Empty means every
+	// location of the shop.
+	Locations []string
+	// OutputDir is where exports are written. Defaults to the working
+	// directory.
+	OutputDir string
+	// Logger receives the Service's records, each with a run_id field.
+	// Defaults to a text logger on stderr at LevelInfo, or LevelDebug
+	// with Debug.
+	Logger Logger
+	// RunID names this run in the log and the inventory backup. Defaults
+	// to a new ID.
+	RunID string
+	// Debug logs every GraphQL request and response at LevelDebug.
+	Debug bool
+	// Bulk runs the order, member and inventory exports as bulk operations
+	// instead of paging through them.
+	Bulk bool
+	// BulkPoll is how often a running bulk operation is checked. Defaults
+	// to 5 seconds.
+	BulkPoll time.Duration
+	// RequestTimeout limits each GraphQL request, including the Transport's
+	// retries and waits for the cost bucket. Whole runs are only limited
+	// by the context passed to the Service's methods. Defaults to 2
+	// minutes, negative means no limit.
+	RequestTimeout time.Duration
 }
 
-func NewService(conf Config) *Service {
-	if conf.Shop == "" || conf.AccessToken == "" {
-		panic("Shop and AccessToken required")
+// ErrInvalidConfig is wrapped by the errors NewService returns for a
+// Config it cannot work with.
+var ErrInvalidConfig = errors.New("invalid shopify config")
+
+var (
+	shopName    = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	tokenFormat = regexp.MustCompile(`^shp(at|ca|pa)_[0-9a-f]{32}$`)
+	apiVersion  = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}|unstable)$`)
+)
+
+// NewService checks conf and sets up the client. Shop is the shop's
+// handle, with or without .myshopify.com.
+func NewService(conf Config) (*Service, error) {
+	shop := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(conf.Shop)), ".myshopify.com")
+	switch {
+	case shop == "":
+		return nil, fmt.Errorf("%w: Shop is required", ErrInvalidConfig)
+	case !shopName.MatchString(shop):
+		return nil, fmt.Errorf("%w: shop %q is not a myshopify.com handle", ErrInvalidConfig, conf.Shop)
+	case conf.AccessToken == "":
+		return nil, fmt.Errorf("%w: AccessToken is required", ErrInvalidConfig)
+	case !tokenFormat.MatchString(conf.AccessToken):
+		// never echo the token itself
+		return nil, fmt.Errorf("%w: AccessToken is not an Admin API token (shpat_, shpca_ or shppa_ followed by 32 hex digits)", ErrInvalidConfig)
 	}
-	return &Service{
-		apiMeta: apiMeta{
-			accessToken: conf.AccessToken,
-			shop:        conf.Shop,
-			endpoint:    fmt.Sprintf("https://%s.myshopify.com/admin/api/2023-01/graphql.json", conf.Shop),
-			locationID:  "gid://shopify/Location/71752646907",
-		},
+	version := conf.APIVersion
+	if version == "" {
+		version = DefaultAPIVersion
 	}
-} // ./NewService
-
-func (s Service) writeMembersLine(c Customer, w *csv.Writer) error {
-	tags := map[string]bool{}
-	for _, v := range c.Tags {
-		tags[v] = true
-	}
-	priceClass := "Retail"
-	if _, ok := tags["wholesale"]; ok {
-		priceClass = "Wholesale"
-	}
-	a := MailingAddress{}
-	if c.DefaultAddress != nil {
-		a = *c.DefaultAddress
-	}
-	company := ""
-	if a.Company == "NULL" || a.Company == "Null" || a.Company == "null" {
-		company = ""
-	} else {
-		company = a.Company
-	}
-	sepMem := strings.Split(c.ID, "/")
-	memID := sepMem[len(sepMem)-1]
-	err := w.Write([]string{
-		memID,
-		c.CustomerNumber.Value,
-		c.Email,
-		c.FirstName,
-		c.LastName,
-		company,
-		a.Address1,
-		ReplaceNull(a.Address2),
-		a.City,
-		a.State,
-		a.Zip,
-		a.Country,
-		a.Region,
-		FormatPhone(c.Phone),
-		"",
-		FormatPhone(c.Phone),
-		"",
-		priceClass,
-		"Yes",
-		date.ToSolomonDateFormat(c.CreatedAt),
-		"",
-		"",
-	})
-	if err != nil {
-		return err
+	if !apiVersion.MatchString(version) {
+		return nil, fmt.Errorf("%w: API version %q is not YYYY-MM or unstable", ErrInvalidConfig, version)
 	}
-	w.Flush()
-	return nil
-} // ./writeMembersLine
+	baseURL := conf.BaseURL
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s.myshopify.com", shop)
+	}
+	endpoint := fmt.Sprintf("%s/admin/api/%s/graphql.json", strings.TrimRight(baseURL, "/"), version)
 
-func (s Service) writeLineItems(orderNumber string, ff []Fulfillment, w *csv.Writer) error {
-	if strings.HasPrefix(orderNumber, "#") {
-		orderNumber = strings.ReplaceAll(orderNumber, "#", "")
-		if !strings.HasPrefix(orderNumber, "130000") {
-			orderNumber = "130000" + orderNumber
+	logger := conf.Logger
+	if logger == nil {
+		min := LevelInfo
+		if conf.Debug {
+			min = LevelDebug
 		}
+		logger = NewTextLogger(os.Stderr, min)
 	}
-	// ll []FulfillmentLineItem
-	for _, v := range ff {
-		for _, l := range v.FulfillmentLineItems.Nodes {
-			sepLI := strings.Split(l.LineItem.ID, "/")
-			LIID := sepLI[len(sepLI)-1]
-			// id := sep[len(sep)-1]
-			w.Write([]string{
-				LIID,
-				strings.Replace(orderNumber, "#", "130000", -1),
-				"", // TODO: ITEM VARIANT ID
-				fmt.Sprintf("%.2f", l.LineItem.DiscountedUnitPriceSet.PresentmentMoney.Amount),
-				"0.00",
-				"False",
-				l.LineItem.Variant.Sku,
-				"Each",
-				fmt.Sprintf("%d", l.LineItem.CurrentQuantity),
-				l.LineItem.Variant.Title,
-				fmt.Sprintf("%.4f", l.LineItem.Variant.Weight),
-				fmt.Sprintf("%.2f", l.LineItem.DiscountedUnitPriceSet.PresentmentMoney.Amount),
-				"", // EXTRA PRICE
-				"", // OPTION ID
-				"", // OPTION ID NUMBER MODIFIER
-			})
-			w.Flush()
-		}
+	runID := conf.RunID
+	if runID == "" {
+		runID = newRunID()
 	}
-	return nil
-} // ./writeLineItems
+	log := WithFields(logger, "run_id", runID)
 
-func (s Service) updateCustomerMetafields(c Customer) error {
-	client := graphql.NewClient(s.endpoint)
-	rq := graphql.NewRequest(`
-		mutation updateCustomerMetafields($input: CustomerInput!) {
-			customerUpdate(input: $input) {
-				customer {
-					id
-					metafields(first: 3) {
-						edges {
-							node {
-								id
-								namespace
-								key
-								value
-							}
-						}
-					}
-				}
-				userErrors {
-					message
-					field
-				}
-			}
-	  	}
-	`)
+	hc := http.Client{}
+	if conf.HTTPClient != nil {
+		hc = *conf.HTTPClient
+	}
+	t, ok := hc.Transport.(*Transport)
+	if !ok {
+		t = NewTransport(hc.Transport)
+		hc.Transport = t
+	}
+	if t.Logger == nil {
+		t.Logger = log
+	}
+	client := graphql.NewClient(endpoint, graphql.WithHTTPClient(&hc))
+	download := hc
+	download.Transport = t.Base
+	if conf.Debug {
+		client.Log = func(s string) { log.Log(LevelDebug, s) }
+	}
+	bulkPoll := conf.BulkPoll
+	if bulkPoll <= 0 {
+		bulkPoll = 5 * time.Second
+	}
+	timeout := conf.RequestTimeout
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+
+	return &Service{
+		apiMeta: apiMeta{
+			accessToken: conf.AccessToken,
+			shop:        shop,
+			endpoint:    endpoint,
+			locs:        &locationCache{wanted: conf.Locations},
+			gql:         client,
+			log:         log,
+			timeout:     timeout,
+		},
+		logger:    logger,
+		runID:     runID,
+		outputDir: conf.OutputDir,
+		download:  &download,
+		bulk:      conf.Bulk,
+		bulkPoll:  bulkPoll,
+	}, nil
+} // ./NewService
+
+// Run sends an arbitrary GraphQL request through the Service's client.
+func (s Service) Run(ctx context.Context, rq *graphql.Request, resp interface{}) error {
+	return s.run(ctx, rq, resp)
+} // ./Run
+
+func (s Service) updateCustomerMetafields(ctx context.Context, c Customer) error {
+	rq := newRequest("update_customer_metafields")
 	type metafields struct {
 		ID  string `json:"id,omitempty"`
 		Ns  string `json:"namespace"`
@@ -188,75 +227,22 @@ func (s Service) updateCustomerMetafields(c Customer) error {
 		ID: c.ID,
 	}
 	rq.Var("input", in)
-	rq.Header.Add("X-Shopify-Access-Token", s.accessToken)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
 
 	type response struct {
-		CustomerUpdate *struct {
-			Customer Customer `json:"customer"`
+		CustomerUpdate struct {
+			Customer   Customer     `json:"customer"`
+			UserErrors []UserErrors `json:"userErrors"`
 		} `json:"customerUpdate"`
-		UserErrors struct {
-			Message string `json:"message"`
-			Field   string `json:"field"`
-		} `json:"userErrors"`
 	}
-	var i GetRaw
-	// var i response
-
-	err := client.Run(ctx, rq, &i)
+	var rs response
+	err := s.run(ctx, rq, &rs)
 	if err != nil {
 		return err
 	}
-	fmt.Println(string(i.Raw))
-	return nil
+	return checkUserErrors("customerUpdate", c.ID, rs.CustomerUpdate.UserErrors)
 } // ./updateCustomerMetafields
 
-func (s Service) UpdateOrderTags(o Order, tags ...string) error {
-	client := graphql.NewClient(s.endpoint)
-	rq := graphql.NewRequest(`
-		mutation updateOrderTags($input: OrderInput!) {
-			orderUpdate(input: $input) {
-				order{
-					id
-					tags
-				}
-				userErrors {
-					message
-					field
-				}
-			}
-	  	}
-	`)
-	in := map[string]interface{}{
-		"id":   o.ID,
-		"tags": tags,
-	}
-	rq.Var("input", in)
-	rq.Header.Add("X-Shopify-Access-Token", s.accessToken)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	type response struct {
-		OrderUpdate struct {
-			Order Order `json:"order"`
-		} `json:"orderUpdate"`
-		UserErrors UserErrors `json:"userErrors"`
-	}
-	var res response
-	client.Log = func(s string) { log.Println(s) }
-	err := client.Run(ctx, rq, &res)
-	if err != nil {
-		return err
-	}
-	return nil
-} // ./updateOrderTags
-
-func (s Service) OrderClosedAddArchiveTag() error {
-	return nil
-} // ./OrderClosedAddArchiveTag
-
-func (s Service) SolomonMembersMapMetafields() error {
+func (s Service) SolomonMembersMapMetafields(ctx context.Context) error {
 	type custInfo struct {
 		CustomerNumber string
 		TaxID          string
@@ -265,9 +251,10 @@ func (s Service) SolomonMembersMapMetafields() error {
 	emailsMap := map[string]custInfo{}
 	addressMap := map[string]custInfo{}
 
-	fsol, err := os.OpenFile("solomon_members_clean.csv", os.O_RDONLY, 0644)
+	const members = "solomon_members_clean.csv"
+	fsol, err := os.OpenFile(members, os.O_RDONLY, 0644)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer fsol.Close()
 	rsol := csv.NewReader(fsol)
@@ -290,7 +277,11 @@ func (s Service) SolomonMembersMapMetafields() error {
 			break
 		}
 		if err != nil {
-			return err
+			return fmt.Errorf("%s: %w", members, err)
+		}
+		if len(rows) < 11 {
+			line, _ := rsol.FieldPos(0)
+			return fmt.Errorf("%s:%d: %d columns, want 11", members, line, len(rows))
 		}
 		email := strings.ToLower(rows[10])
 		if email == "test@cuestik.com" {
@@ -308,86 +299,12 @@ func (s Service) SolomonMembersMapMetafields() error {
 		}
 	}
 
-	client := graphql.NewClient(s.endpoint)
-	type response struct {
-		Customers struct {
-			Edges []struct {
-				Customer Customer `json:"node"`
-			} `json:"edges"`
-			PageInfo struct {
-				StartCursor string `json:"startCursor"`
-				EndCursor   string `json:"endCursor"`
-				HasNextPage bool   `json:"hasNextPage"`
-			} `json:"pageInfo"`
-		} `json:"customers"`
-	}
-
-	var i response
-	ctx := context.Background()
-	hasNextPage := true
-	after := ""
-	for hasNextPage {
-		rq := graphql.NewRequest(fmt.Sprintf(`
-			{
-				customers(first:150%s) {
-					edges {
-						node {
-							id
-							email
-							firstName
-							lastName
-							defaultAddress{
-								address1
-								address2
-								city
-								state:provinceCode
-								zip
-								countryCodeV2
-								region:provinceCode
-								company
-							}
-							addresses{
-								address1
-								address2
-								city
-								state:provinceCode
-								zip
-								countryCodeV2
-								region:provinceCode
-								company
-							}
-							phone
-							taxExempt
-							taxExemptions
-							customer_number:metafield(namespace: "custom", key:"customer_number") {
-								id
-								value
-							}
-							tax_exempt_id:metafield(namespace: "custom", key: "tax_exempt_id") {
-								id
-								value
-							}
-							tags
-							createdAt
-						}
-					}
-					pageInfo {
-						startCursor
-						endCursor
-						hasNextPage
-					}
-				}
-			}
-		`, after))
-		rq.Header.Add("X-Shopify-Access-Token", s.accessToken)
-
-		err = client.Run(ctx, rq, &i)
-		if err != nil {
-			return err
-		}
-
-		for _, e := range i.Customers.Edges {
-			c := e.Customer
+	refused := []*UserErrorsError{}
+	p := s.paginate("customers", "customers")
+	p.First = 150
+	var page []Customer
+	for p.Next(ctx, &page) {
+		for _, c := range page {
 			tags := map[string]bool{}
 			for _, v := range c.Tags {
 				tags[v] = true
@@ -460,823 +377,676 @@ func (s Service) SolomonMembersMapMetafields() error {
 			}
 			c.CustomerNumber.Value = custNumber
 			c.TaxExemptID.Value = taxID
-			if i.Customers.PageInfo.HasNextPage {
-				after = fmt.Sprintf(" after: \"%s\"", i.Customers.PageInfo.EndCursor)
+			err = s.updateCustomerMetafields(ctx, c)
+			var ue *UserErrorsError
+			if errors.As(err, &ue) {
+				s.log.Log(LevelWarn, "customer not updated", "customer_id", c.ID, "error", ue)
+				refused = append(refused, ue)
+				continue
 			}
-
-			hasNextPage = i.Customers.PageInfo.HasNextPage
-			err = s.updateCustomerMetafields(c)
 			if err != nil {
-				return err
+				return fmt.Errorf("customer %s: %w", c.ID, err)
 			}
 		}
 	}
+	if err := p.Err(); err != nil {
+		return err
+	}
+	if len(refused) > 0 {
+		return &BatchError{Failures: refused}
+	}
 	return nil
 } // ./SolomonMembersMapMetafields
 
-func (s Service) SolomonMembersExport() error {
-	client := graphql.NewClient(s.endpoint)
-
-	f, err := os.OpenFile("MEMBERS.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+func (s Service) SolomonMembersExport(ctx context.Context) error {
+	es, err := newExportSet(s.outputDir, "members_export.manifest.json")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	w := csv.NewWriter(f)
-	w.Write([]string{
-		"MEMBER_ID",
-		"CustId",
-		"EMAIL",
-		"FIRST_NAME",
-		"LAST_NAME",
-		"COMPANY_NAME",
-		"ADDRESS1",
-		"ADDRESS2",
-		"CITY",
-		"STATE_CODE",
-		"ZIP",
-		"COUNTRY_CODE",
-		"REGION",
-		"PHONE",
-		"FAX",
-		"CELL",
-		"Terms",
-		"PRICE_CLASS",
-		"APPROVAL_PENDING",
-		"DATE_CREATED",
-		"LAST_UPDATED",
-		"NOTES",
-	})
-	w.Flush()
+	defer es.abort()
 
-	type response struct {
-		Customers struct {
-			Edges []struct {
-				Customer Customer `json:"node"`
-			} `json:"edges"`
-			PageInfo struct {
-				StartCursor string `json:"startCursor"`
-				EndCursor   string `json:"endCursor"`
-				HasNextPage bool   `json:"hasNextPage"`
-			} `json:"pageInfo"`
-		} `json:"customers"`
+	w, err := es.createSolomon("MEMBERS.txt")
+	if err != nil {
+		return err
+	}
+	enc, err := solomon.NewEncoder(w, solomon.Member{})
+	if err != nil {
+		return err
+	}
+	err = enc.WriteHeader()
+	if err != nil {
+		return err
 	}
 
-	var i response
-	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
-	defer cancel()
-	hasNextPage := true
-	after := ""
-	for hasNextPage {
-		rq := graphql.NewRequest(fmt.Sprintf(`
-			{
-				customers(first:150%s) {
-					edges {
-						node {
-							id
-							email
-							firstName
-							lastName
-							defaultAddress{
-								address1
-								address2
-								city
-								state:provinceCode
-								zip
-								countryCodeV2
-								region:provinceCode
-								company
-							}
-							addresses{
-								address1
-								address2
-								city
-								state:provinceCode
-								zip
-								countryCodeV2
-								region:provinceCode
-								company
-							}
-							phone
-							taxExempt
-							taxExemptions
-							customer_number:metafield(namespace: "custom", key:"customer_number") {
-								value
-							}
-							tax_exempt_id:metafield(namespace: "custom", key: "tax_exempt_id") {
-								value
-							}
-							tags
-							createdAt
-						}
-					}
-					pageInfo {
-						startCursor
-						endCursor
-						hasNextPage
-					}
-				}
+	if s.bulk {
+		err = s.RunBulkQuery(ctx, bulkQuery("bulk_customers", nil), func(node json.RawMessage) error {
+			var c Customer
+			err := json.Unmarshal(node, &c)
+			if err != nil {
+				return err
 			}
-		`, after))
-		rq.Header.Add("X-Shopify-Access-Token", s.accessToken)
-
-		err = client.Run(ctx, rq, &i)
+			err = enc.Encode(SolomonMember(c))
+			if err != nil {
+				return fmt.Errorf("customer %s: %w", c.ID, err)
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-
-		for _, c := range i.Customers.Edges {
-			tags := map[string]bool{}
-			for _, v := range c.Customer.Tags {
-				tags[v] = true
-			}
-			priceClass := "Retail"
-			if _, ok := tags["wholesale"]; ok {
-				priceClass = "Wholesale"
-			}
-			a := MailingAddress{}
-			if c.Customer.DefaultAddress != nil {
-				a = *c.Customer.DefaultAddress
-			}
-			company := ""
-			if a.Company == "NULL" || a.Company == "Null" || a.Company == "null" {
-				company = ""
-			} else {
-				company = a.Company
-			}
-			err := w.Write([]string{
-				c.Customer.ID,
-				c.Customer.CustomerNumber.Value,
-				c.Customer.Email,
-				c.Customer.FirstName,
-				c.Customer.LastName,
-				company,
-				a.Address1,
-				ReplaceNull(a.Address2),
-				a.City,
-				a.State,
-				a.Zip,
-				a.Country,
-				a.Region,
-				FormatPhone(c.Customer.Phone),
-				"",
-				FormatPhone(c.Customer.Phone),
-				"",
-				priceClass,
-				"Yes",
-				date.ToSolomonDateFormat(c.Customer.CreatedAt),
-				"",
-				"",
-			})
-			if err != nil {
-				return err
-			}
-			w.Flush()
-			if i.Customers.PageInfo.HasNextPage {
-				after = fmt.Sprintf(" after: \"%s\"", i.Customers.PageInfo.EndCursor)
+	} else {
+		p := s.paginate("customers", "customers")
+		p.First = 150
+		var page []Customer
+		for p.Next(ctx, &page) {
+			for _, c := range page {
+				err := enc.Encode(SolomonMember(c))
+				if err != nil {
+					return fmt.Errorf("customer %s: %w", c.ID, err)
+				}
 			}
-			hasNextPage = i.Customers.PageInfo.HasNextPage
+		}
+		if err := p.Err(); err != nil {
+			return err
 		}
 	}
-	return nil
+	_, err = es.commit()
+	return err
 } // ./SolomonMembersExport
 
-func (s Service) GenSolonomFiles(query string) error {
-	client := graphql.NewClient(s.endpoint)
+type OrderExportOptions struct {
+	// Query is the Shopify order search query.
+	Query string
+	// StateFile turns on incremental mode: only orders updated since the
+	// checkpoint in StateFile are exported, and the checkpoint is advanced
+	// once the export is committed.
+	StateFile string
+	// Since is the lower bound on updatedAt when StateFile holds no
+	// checkpoint yet. Zero means no bound.
+	Since time.Time
+	// Tag is added to every exported order once the files are committed,
+	// orders that already have it are skipped. Defaults to "exported".
+	Tag string
+	// UntaggedFile keeps the orders that were exported but could not be
+	// tagged. They are not exported again, only their tagging is retried
+	// by the next run. Defaults to "orders_untagged.csv".
+	UntaggedFile string
+}
 
-	// init store orders file
-	fOrders, err := os.OpenFile("STORE_ORDERS.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+type ExportReport struct {
+	Manifest *Manifest
+	// Exported lists the IDs of the orders written by this run.
+	Exported []string
+	// Untagged lists the orders, from this or earlier runs, that are still
+	// missing the exported tag.
+	Untagged []TagFailure
+}
+
+func (s Service) GenSolonomFiles(ctx context.Context, opts OrderExportOptions) (*ExportReport, error) {
+	if opts.Tag == "" {
+		opts.Tag = "exported"
+	}
+	if opts.UntaggedFile == "" {
+		opts.UntaggedFile = "orders_untagged.csv"
+	}
+	untagged, err := loadTagFailures(opts.UntaggedFile)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	pending := map[string]bool{}
+	for _, f := range untagged {
+		pending[f.ID] = true
 	}
-	defer fOrders.Close()
 
-	wOrders := csv.NewWriter(fOrders)
-	wOrders.Write([]string{
-		"ORDER_ID",
-		"CustId",
-		"ORDER_NR",
-		"ADMIN_CODE",
-		"MEMBER_ID",
-		"BILLING_FIRST_NAME",
-		"BILLING_LAST_NAME",
-		"BILLING_COMPANY",
-		"BILLING_ADDRESS1",
-		"BILLING_ADDRESS2",
-		"BILLING_CITY",
-		"BILLING_STATE",
-		"BILLING_COUNTRY",
-		"BILLING_ZIP",
-		"BILLING_PHONE",
-		"SHIPPING_FIRST_NAME",
-		"SHIPPING_LAST_NAME",
-		"SHIPPING_COMPANY",
-		"SHIPPING_ADDRESS1",
-		"SHIPPING_ADDRESS2",
-		"SHIPPING_CITY",
-		"SHIPPING_STATE",
-		"SHIPPING_COUNTRY",
-		"SHIPPING_ZIP",
-		"SHIPPING_PHONE",
-		"SHIPPING_CODE",
-		"Terms",
-		"EMAIL",
-		"BASE_SUBTOTAL",
-		"SUBTOTAL",
-		"TAX_AMOUNT",
-		"SHIPPING_AMOUNT",
-		"TOTAL",
-		"CREATE_DATE",
-		"PROCESS_DATE",
-		"SETTLE_DATE",
-		"INVOICED_DATE",
-		"SHIPPED_DATE",
-		"SMALL_ORDER_FEE",
-		"LARGE_ORDER_DISCOUNT",
-	})
-	wOrders.Flush()
+	query := opts.Query
+	sortKey := ""
+	var cp Checkpoint
+	if opts.StateFile != "" {
+		cp, err = LoadCheckpoint(opts.StateFile)
+		if err != nil {
+			return nil, err
+		}
+		if cp.IsZero() && !opts.Since.IsZero() {
+			cp.UpdatedAt = opts.Since
+		}
+		query = cp.Query(query)
+		sortKey = "UPDATED_AT"
+	}
+	next := cp
+	exported := []string{}
 
-	// init store cart items file
-	fCartItems, err := os.OpenFile("STORE_CART_ITEMS.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	es, err := newExportSet(s.outputDir, "orders_export.manifest.json")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer fCartItems.Close()
+	defer es.abort()
 
-	wCartItems := csv.NewWriter(fCartItems)
-	wCartItems.Write([]string{
-		"CART_ITEM_ID",
-		"ORDER_NR",
-		"ITEM_VARIANT_ID",
-		"ITEM_PRICE",
-		"SALE_PRICE",
-		"IS_ON_SALE",
-		"ITEM_NUMBER",
-		"UNIT_OF_MEASURE",
-		"ITEM_QUANTITY",
-		"ITEM_NAME",
-		"WEIGHT",
-		"PRICE",
-		"EXTRA_PRICE",
-		"OPTION_ID",
-		"OPTION_ITEM_NUMBER_MODIFIER",
-	})
-	wCartItems.Flush()
-
-	// init members items file
-	fMembers, err := os.OpenFile("MEMBERS.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	// init store orders file
+	wOrders, err := es.createSolomon("STORE_ORDERS.txt")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	encOrders, err := solomon.NewEncoder(wOrders, solomon.StoreOrder{})
+	if err != nil {
+		return nil, err
 	}
-	defer fMembers.Close()
 
-	wMembers := csv.NewWriter(fMembers)
-	wMembers.Write([]string{
-		"MEMBER_ID",
-		"CustId",
-		"EMAIL",
-		"FIRST_NAME",
-		"LAST_NAME",
-		"COMPANY_NAME",
-		"ADDRESS1",
-		"ADDRESS2",
-		"CITY",
-		"STATE_CODE",
-		"ZIP",
-		"COUNTRY_CODE",
-		"REGION",
-		"PHONE",
-		"FAX",
-		"CELL",
-		"Terms",
-		"PRICE_CLASS",
-		"APPROVAL_PENDING",
-		"DATE_CREATED",
-		"LAST_UPDATED",
-		"NOTES",
-	})
-	wMembers.Flush()
+	// init store cart items file
+	wCartItems, err := es.createSolomon("STORE_CART_ITEMS.txt")
+	if err != nil {
+		return nil, err
+	}
+	encCartItems, err := solomon.NewEncoder(wCartItems, solomon.StoreCartItem{})
+	if err != nil {
+		return nil, err
+	}
 
-	type response struct {
-		Orders struct {
-			Edges []struct {
-				Order Order `json:"node"`
-			} `json:"edges"`
-			PageInfo struct {
-				StartCursor string `json:"startCursor"`
-				EndCursor   string `json:"endCursor"`
-				HasNextPage bool   `json:"hasNextPage"`
-			} `json:"pageInfo"`
-		} `json:"orders"`
+	// init members items file
+	wMembers, err := es.createSolomon("MEMBERS.txt")
+	if err != nil {
+		return nil, err
+	}
+	encMembers, err := solomon.NewEncoder(wMembers, solomon.Member{})
+	if err != nil {
+		return nil, err
 	}
 
-	hasNextPage := true
-	after := ""
+	for _, enc := range []*solomon.Encoder{encOrders, encCartItems, encMembers} {
+		err = enc.WriteHeader()
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
-	for hasNextPage {
-		rq := graphql.NewRequest(fmt.Sprintf(`
-			{
-				orders(first:1%s, query:"%s"){
-					edges{
-						node{
-							id
-							order_number:name
-							customer{
-								id
-								email
-								firstName
-								lastName
-								defaultAddress{
-									address1
-									address2
-									city
-									state:provinceCode
-									zip
-									countryCodeV2
-									region:provinceCode
-									company
-								}
-								addresses{
-									address1
-									address2
-									city
-									state:provinceCode
-									zip
-									countryCodeV2
-									region:provinceCode
-									company
-								}
-								phone
-								taxExempt
-								taxExemptions
-								customer_number:metafield(namespace: "custom", key:"customer_number") {
-									value
-								}
-								tax_exempt_id:metafield(namespace: "custom", key: "tax_exempt_id") {
-									value
-								}
-								tags
-								createdAt
-							}
-							billingAddress{
-								firstName
-								lastName
-								phone
-								address1
-								address2
-								city
-								state:provinceCode
-								zip
-								countryCodeV2
-								region:provinceCode
-								company
-							}
-							billingAddressMatchesShippingAddress
-							shippingAddress{
-								firstName
-								lastName
-								phone
-								address1
-								address2
-								city
-								state:provinceCode
-								zip
-								countryCodeV2
-								region:provinceCode
-								company
-							}
-							paymentTerms{
-								paymentTermsName
-								paymentTermsType
-							}
-							phone
-							email
-							createdAt
-							processedAt
-							closedAt
-							currentSubtotalPriceSet{
-								presentmentMoney{
-									amount
-									currencyCode
-								}
-							}
-							currentTotalTaxSet{
-								presentmentMoney{
-									amount
-									currencyCode
-								}
-							}
-							totalShippingPriceSet{
-								presentmentMoney{
-									amount
-									currencyCode
-								}
-							}
-							currentTotalPriceSet{
-								presentmentMoney{
-									amount
-									currencyCode
-								}
-							}
-							totalReceivedSet {
-								presentmentMoney {
-									amount
-									currencyCode
-								}
-							}
-							fulfillments(first:100) {
-								fulfillmentLineItems(first: 160) {
-									nodes {
-										lineItem {
-											id
-											sku
-											title
-											discountedUnitPriceSet {
-												presentmentMoney {
-													amount
-												}
-											}
-											product {
-												title
-												handle
-											}
-											variant {
-												id
-												displayName
-												title
-												sku
-												price
-												weight
-												inventoryQuantity
-											}
-											currentQuantity
-										}
-										discountedTotalSet {
-											presentmentMoney {
-												amount
-											}
-										}
-									}
-								}
-							}
-							displayFinancialStatus
-							displayFulfillmentStatus
-							closed
-						}
-					}
-					pageInfo{
-						hasNextPage
-						endCursor
-					}
-				}
-			}
-		`, after, query))
-		rq.Header.Add("X-Shopify-Access-Token", s.accessToken)
-		var rs response
-		// var i GetRaw
-		client.Log = func(s string) { log.Println(s) }
-		err := client.Run(ctx, rq, &rs)
+	handle := func(o Order) error {
+		if cp.Covers(o) {
+			return nil
+		}
+		next = next.Advance(o)
+		if pending[o.ID] {
+			// exported before, only the tag is missing
+			return nil
+		}
+		if o.HasTag(opts.Tag) {
+			// tagging it moved updatedAt past the checkpoint
+			return nil
+		}
+		// if o.Closed {
+		// 	continue
+		// }
+		if o.DisplayFulfillmentStatus != "FULFILLED" {
+			return nil
+		}
+		if o.Test {
+			return nil
+		}
+		err := s.completeFulfillments(ctx, &o)
 		if err != nil {
 			return err
 		}
-		for _, e := range rs.Orders.Edges {
-			o := e.Order
-			// if o.Closed {
-			// 	continue
-			// }
-			if o.DisplayFulfillmentStatus != "FULFILLED" {
-				continue
-			}
-			if o.Test {
-				continue
-			}
-			c := o.Customer
-			err = s.writeMembersLine(c, wMembers)
+		m := SolomonMember(o.Customer)
+		// the order export has always used the numeric ID, as in
+		// STORE_ORDERS.txt
+		m.MemberID = legacyID(o.Customer.ID)
+		err = encMembers.Encode(m)
+		if err != nil {
+			return err
+		}
+		err = encOrders.Encode(SolomonStoreOrder(o))
+		if err != nil {
+			return err
+		}
+		exported = append(exported, o.ID)
+		s.log.Log(LevelDebug, "order exported", "order_id", o.ID, "order_number", o.OrderNumber)
+		for _, ci := range SolomonCartItems(o) {
+			err = encCartItems.Encode(ci)
 			if err != nil {
 				return err
 			}
-			billA := o.BillingAddress
-			shipA := o.ShippingAddress
-			if o.BillingAddressMatchesShippingAddress {
-				billA = shipA
-			}
-			sep := strings.Split(o.ID, "/")
-			id := sep[len(sep)-1]
-
-			sepMem := strings.Split(c.ID, "/")
-			memID := sepMem[len(sepMem)-1]
-			orderNumber := o.OrderNumber
-			if strings.HasPrefix(orderNumber, "#") {
-				orderNumber = strings.ReplaceAll(orderNumber, "#", "")
-				if !strings.HasPrefix(orderNumber, "130000") {
-					orderNumber = "130000" + orderNumber
-				}
-			}
-
-			err := wOrders.Write([]string{
-				id,
-				c.CustomerNumber.Value,
-				orderNumber,
-				"WEB",
-				memID, // MEMBER ID
-				billA.FirstName,
-				billA.LastName,
-				billA.Company,
-				billA.Address1,
-				billA.Address2,
-				billA.City,
-				billA.State,
-				billA.Country,
-				billA.Zip,
-				FormatPhone(billA.Phone),
-				shipA.FirstName,
-				shipA.LastName,
-				shipA.Company,
-				shipA.Address1,
-				shipA.Address2,
-				shipA.City,
-				shipA.State,
-				shipA.Country,
-				shipA.Zip,
-				FormatPhone(shipA.Phone),
-				"NA", // SHIPPING CODE
-				"CC", // TERMS
-				c.Email,
-				fmt.Sprintf("%.2f", o.CurrentSubtotalPriceSet.PresentmentMoney.Amount),
-				fmt.Sprintf("%.2f", o.CurrentSubtotalPriceSet.PresentmentMoney.Amount),
-				fmt.Sprintf("%.2f", o.CurrentTotalTaxSet.PresentmentMoney.Amount),
-				fmt.Sprintf("%.2f", o.TotalShippingPriceSet.PresentmentMoney.Amount),
-				fmt.Sprintf("%.2f", o.TotalReceivedSet.PresentmentMoney.Amount),
-				date.ToSolomonDateFormat(o.CreatedAt),
-				date.ToSolomonDateFormat(o.CreatedAt), // PROCESS DATE TODO: TEMP
-				date.ToSolomonDateFormat(o.ClosedAt),
-				"",                                    // INVOICED_DATE
-				date.ToSolomonDateFormat(o.CreatedAt), // SHIPPED DATE TODO: TEMP
-				"",                                    // SMALL ORDER FEE
-				"",                                    // LARGE ORDER DISCOUNT
-			})
+		}
+		return nil
+	}
+	var runErr error
+	if s.bulk {
+		runErr = s.RunBulkQuery(ctx, bulkQuery("bulk_orders", map[string]interface{}{"query": query}), func(node json.RawMessage) error {
+			var o Order
+			err := json.Unmarshal(node, &o)
 			if err != nil {
 				return err
 			}
-			wOrders.Flush()
-
-			err = s.writeLineItems(orderNumber, o.Fulfillments, wCartItems)
+			err = handle(o)
 			if err != nil {
-				return err
+				return fmt.Errorf("order %s: %w", o.ID, err)
+			}
+			return nil
+		})
+	} else {
+		p := s.paginate("orders_export", "orders")
+		p.First = 1
+		p.Var("query", query)
+		p.Var("sortKey", optional(sortKey))
+		var page []Order
+	pages:
+		for p.Next(ctx, &page) {
+			for _, o := range page {
+				err = handle(o)
+				if err != nil {
+					runErr = fmt.Errorf("order %s: %w", o.ID, err)
+					break pages
+				}
 			}
 		}
-		if rs.Orders.PageInfo.HasNextPage {
-			after = fmt.Sprintf(" after: \"%s\"", rs.Orders.PageInfo.EndCursor)
+		if runErr == nil {
+			runErr = p.Err()
 		}
-		hasNextPage = rs.Orders.PageInfo.HasNextPage
 	}
-	cmd := exec.Command("python3", "format_csv.py", "STORE_ORDERS.txt", "STORE_CART_ITEMS.txt", "MEMBERS.txt")
-	err = cmd.Run()
-	return err
-} // ./GenSolonomFiles
+	// when ctx is done the orders written so far are still committed,
+	// they end up in the untagged file and are only tagged next run
+	if runErr != nil && ctx.Err() == nil {
+		return nil, runErr
+	}
+	m, err := es.commit()
+	if err != nil {
+		return nil, err
+	}
+	rep := &ExportReport{Manifest: m, Exported: exported}
+	s.log.Log(LevelInfo, "orders exported", "orders", len(exported), "retried", len(untagged))
 
-func (s Service) SolomonInventoryExport() error {
-	client := graphql.NewClient(s.endpoint)
-	hasNextPage := true
-	after := ""
-	ii := []InventoryItem{}
-	for hasNextPage {
-		rq := graphql.NewRequest(fmt.Sprintf(`
-			{
-				inventoryItems(first: 250%s) {
-					edges {
-						cursor
-						node {
-							id
-							sku
-							unitCost {
-								amount
-								currencyCode
-							}
-							duplicateSkuCount
-							variant {
-								id
-								displayName
-								sku
-								barcode
-								inventoryQuantity
-								price
-							}
-						}
-					}
-					pageInfo {
-						startCursor
-						endCursor
-						hasNextPage
-					}
-				}
-			}
-		`, after))
-		rq.Header.Add("X-Shopify-Access-Token", s.accessToken)
-		type response struct {
-			InventoryItems struct {
-				Edges []struct {
-					Cursor string
-					Node   InventoryItem `json:"node"`
-				} `json:"edges"`
-				PageInfo struct {
-					StartCursor string `json:"startCursor"`
-					EndCursor   string `json:"endCursor"`
-					HasNextPage bool   `json:"hasNextPage"`
-				} `json:"pageInfo"`
-			} `json:"inventoryItems"`
-		}
+	ids := append([]string{}, exported...)
+	for _, f := range untagged {
+		ids = append(ids, f.ID)
+	}
+	rep.Untagged, err = s.tagBatches(ctx, "tagsAdd", ids, []string{opts.Tag})
+	for _, f := range rep.Untagged {
+		s.log.Log(LevelWarn, "order not tagged", "order_id", f.ID, "error", f.Error)
+	}
+	serr := saveTagFailures(opts.UntaggedFile, rep.Untagged)
+	if err != nil {
+		return rep, err
+	}
+	if serr != nil {
+		return rep, serr
+	}
+	if runErr != nil {
+		// the next run starts from the old checkpoint again
+		return rep, runErr
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		var rs response
-		err := client.Run(ctx, rq, &rs)
+	if opts.StateFile != "" && next != cp {
+		err = next.Save(opts.StateFile)
 		if err != nil {
-			return err
+			return rep, err
+		}
+	}
+	return rep, nil
+} // ./GenSolonomFiles
+
+// completeFulfillments reads the fulfillment line items of o that did not
+// fit in the orders query.
+func (s Service) completeFulfillments(ctx context.Context, o *Order) error {
+	for i := range o.Fulfillments {
+		f := &o.Fulfillments[i]
+		if !f.FulfillmentLineItems.PageInfo.HasNextPage {
+			continue
 		}
-		for _, i := range rs.InventoryItems.Edges {
-			ii = append(ii, i.Node)
+		p := s.paginate("fulfillment_line_items", "fulfillment", "fulfillmentLineItems")
+		p.First = 250
+		p.Var("id", f.ID).After(f.FulfillmentLineItems.PageInfo.EndCursor)
+		var page []FulfillmentLineItem
+		for p.Next(ctx, &page) {
+			f.FulfillmentLineItems.Nodes = append(f.FulfillmentLineItems.Nodes, page...)
 		}
-		if rs.InventoryItems.PageInfo.HasNextPage {
-			after = fmt.Sprintf(" after: \"%s\"", rs.InventoryItems.PageInfo.EndCursor)
+		if err := p.Err(); err != nil {
+			return fmt.Errorf("fulfillment %s: %w", f.ID, err)
 		}
-		hasNextPage = rs.InventoryItems.PageInfo.HasNextPage
 	}
+	return nil
+} // ./completeFulfillments
 
-	f, err := os.OpenFile("ABS Inventory Quantities.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+func (s Service) SolomonInventoryExport(ctx context.Context) error {
+	locations, err := s.Locations(ctx)
+	if err != nil {
+		return err
+	}
+	ii, err := s.allInventoryItems(ctx)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	w := csv.NewWriter(f)
-	w.Comma = '\t'
-	w.Write([]string{
-		"InventoryID",
-		"Description",
-		"StockingUOM",
-		"PurchasingUOM",
-		"SellingUOM",
-		"StatusCode",
-		"Quantity",
-	})
-	w.Flush()
+	es, err := newExportSet(s.outputDir, "inventory_export.manifest.json")
+	if err != nil {
+		return err
+	}
+	defer es.abort()
+
+	w, err := es.create("ABS Inventory Quantities.txt", '\t')
+	if err != nil {
+		return err
+	}
+	enc, err := solomon.NewEncoder(w, solomon.InventoryQuantity{})
+	if err != nil {
+		return err
+	}
+	err = enc.WriteHeader()
+	if err != nil {
+		return err
+	}
 	for _, i := range ii {
-		w.Write([]string{
-			i.Variant.Sku,
-			i.Variant.DisplayName,
-			"EA",
-			"EA",
-			"EA",
-			"AC",
-			fmt.Sprintf("%d", i.Variant.InventoryQuantity),
-		})
-		w.Flush()
+		for _, q := range SolomonInventoryQuantities(i, locations) {
+			err := enc.Encode(q)
+			if err != nil {
+				return fmt.Errorf("sku %s: %w", i.Sku, err)
+			}
+		}
 	}
-	return nil
+	_, err = es.commit()
+	return err
 } // ./SolomonInventoryExport
 
-func (s Service) UploadInventory() error {
-	f, err := os.OpenFile("ABS Inventory Quantities.txt", os.O_RDONLY, 0644)
+type UploadInventoryOptions struct {
+	// File defaults to "ABS Inventory Quantities.txt".
+	File string
+	// Location (ID or name) is used for rows without a Location column.
+	// Defaults to the Service's default location.
+	Location string
+	// DryRun resolves every row and writes the report without changing
+	// any stock.
+	DryRun bool
+	// Report is where the diff of every row is written as CSV. Defaults
+	// to "inventory_diff.csv".
+	Report string
+	// Backup is where the replaced quantities are appended, for
+	// RestoreInventory. Defaults to "shopify_backup.csv".
+	Backup string
+	// RunID tags this upload's lines in Backup and in the log. Defaults
+	// to the Service's run ID.
+	RunID string
+	// LooseSku matches the file's SKUs to Shopify's ignoring case, e.g.
+	// cue-1 to CUE-1. Surrounding spaces are always ignored.
+	LooseSku bool
+	// Resolutions says which variant wins for SKUs shared by several, see
+	// LoadSkuResolutions. Ambiguous SKUs are skipped. Defaults to
+	// "sku_resolutions.csv".
+	Resolutions string
+}
+
+func (s Service) UploadInventory(ctx context.Context, opts UploadInventoryOptions) (*InventoryReport, error) {
+	if opts.File == "" {
+		opts.File = "ABS Inventory Quantities.txt"
+	}
+	if opts.Report == "" {
+		opts.Report = "inventory_diff.csv"
+	}
+	if opts.Backup == "" {
+		opts.Backup = "shopify_backup.csv"
+	}
+	log := s.log
+	if opts.RunID == "" {
+		opts.RunID = s.runID
+	} else if opts.RunID != s.runID {
+		log = WithFields(s.logger, "run_id", opts.RunID)
+	}
+	if opts.Resolutions == "" {
+		opts.Resolutions = "sku_resolutions.csv"
+	}
+	fileLoc, err := s.Location(ctx, opts.Location)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	defer f.Close()
 
-	fbak, err := os.OpenFile("shopify_backup.csv", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	f, err := os.OpenFile(opts.File, os.O_RDONLY, 0644)
 	if err != nil {
-		panic(err)
-	}
-	defer fbak.Close()
-	wbak := csv.NewWriter(fbak)
-	// wbak.Write([]string{
-	// 	"SKU",
-	// 	"Quantity",
-	// })
-	// wbak.Flush()
+		return nil, err
+	}
+	defer f.Close()
 
-	fnis, err := os.OpenFile("not_in_shopify.csv", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	items, err := s.allInventoryItems(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	index := skuIndex(items, opts.LooseSku)
+	resolutions, err := LoadSkuResolutions(opts.Resolutions)
+	if err != nil {
+		return nil, err
 	}
-	defer fnis.Close()
-	w := csv.NewWriter(fnis)
 
+	rep := &InventoryReport{DryRun: opts.DryRun, RunID: opts.RunID, Diffs: []InventoryDiff{}}
+	notInShopify := [][]string{}
 	r := csv.NewReader(f)
 	r.Comma = '\t'
 	r.FieldsPerRecord = -1
-	first := true
+	rr := &recordKeeper{r: r}
+	dec := solomon.NewDecoder(rr)
+	changes := []*quantityChange{}
+	// a SKU listed twice for the same location keeps its last quantity
+	seen := map[string]*quantityChange{}
 	for {
-		/*
-			InventoryID: 0
-			Description: 1
-			StockingUOM: 2
-			PurchasingUOM: 3
-			SellingUOM: 4
-			StatusCode: 5
-			Quantity: 6
-		*/
-		row, err := r.Read()
+		var q solomon.InventoryQuantity
+		err := dec.Decode(&q)
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
-			return err
-		}
-		if first {
-			// w.Write(row)
-			first = false
-			continue
-		}
-		sku := strings.TrimSpace(row[0])
-		ii, err := s.inventoryItemBySku(sku)
-		if err != nil {
-			fmt.Printf("sku %s: %s\n", sku, err.Error())
-			w.Write(row)
-			continue
+		var perr *solomon.ParseError
+		if err != nil && !errors.As(err, &perr) {
+			return nil, fmt.Errorf("%s: %w", opts.File, err)
 		}
-		if ii == nil {
-			fmt.Printf("sku %s not in Shopify\n", sku)
-			w.Write(row)
-			continue
+		if len(rep.Diffs) == 0 {
+			err = checkColumns(dec.Header(), "InventoryID", "Quantity")
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", opts.File, err)
+			}
 		}
-		wbak.Write([]string{
-			ii.Sku,
-			strconv.Itoa(ii.InventoryLevel.Available),
-		})
-		wbak.Flush()
-		quantity, err := strconv.Atoi(row[6])
-		if err != nil {
-			quantity = 0
+		line, _ := r.FieldPos(0)
+		row := rr.last
+		sku := strings.TrimSpace(q.InventoryID)
+		loc := fileLoc
+		if strings.TrimSpace(q.Location) != "" {
+			loc, err = s.Location(ctx, q.Location)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: sku %s: %w", opts.File, line, sku, err)
+			}
 		}
+		quantity := q.Quantity
 		if quantity < 0 {
 			quantity = 0
 		}
-		available := ii.InventoryLevel.Available
-		delta := 0
-		if quantity > available {
-			delta = quantity - available
-		} else if quantity == available {
-			delta = 0
-		} else {
-			delta = -(available - quantity)
+		d := InventoryDiff{Sku: sku, Location: loc.Name, Quantity: quantity}
+		matches := index[skuKey(sku, opts.LooseSku)]
+		var item *InventoryItem
+		var lvl *InventoryLevel
+		if len(matches) > 0 {
+			item, err = resolutions.Pick(sku, matches)
 		}
-		fmt.Println(sku+" ", delta)
-		err = ii.UpdateQuantity(delta)
-		if err != nil {
-			return err
+		if item != nil {
+			lvl = item.InventoryLevels.At(loc.ID)
+		}
+		logLevel := LevelInfo
+		switch {
+		case perr != nil:
+			// setting it to 0 would empty the stock
+			d.Action, d.Reason = ActionSkip, "bad "+perr.Column+": "+perr.Err.Error()
+			logLevel = LevelWarn
+		case len(matches) == 0:
+			d.Action, d.Reason = ActionSkip, "not in Shopify"
+		case item == nil:
+			// ambiguous, someone has to pick the variant
+			d.Action, d.Reason = ActionSkip, err.Error()
+			logLevel = LevelWarn
+		case lvl == nil:
+			d.Action, d.Reason = ActionSkip, "not stocked at "+loc.Name
+		}
+		if d.Action == ActionSkip {
+			log.Log(logLevel, "sku skipped", "sku", sku, "location", loc.Name, "reason", d.Reason)
+			notInShopify = append(notInShopify, row)
+			rep.Diffs = append(rep.Diffs, d)
+			continue
+		}
+		if len(matches) > 1 {
+			d.Reason = fmt.Sprintf("SKU on %d variants, resolved to %s", len(matches), item.ID)
+		}
+		d.Available = lvl.Available
+		d.Delta = quantity - lvl.Available
+		d.Action = ActionSet
+		if d.Delta == 0 {
+			d.Action = ActionUnchanged
 		}
+		rep.Diffs = append(rep.Diffs, d)
+
+		key := item.ID + " " + loc.ID
+		if c, ok := seen[key]; ok {
+			prev := &rep.Diffs[c.row]
+			prev.Action, prev.Reason, prev.Delta = ActionSkip, "listed again later in the file", 0
+			c.quantity = quantity
+			c.row = len(rep.Diffs) - 1
+			continue
+		}
+		ii := *item
+		level := *lvl
+		ii.InventoryLevel = &level
+		c := &quantityChange{item: ii, quantity: quantity, row: len(rep.Diffs) - 1}
+		seen[key] = c
+		changes = append(changes, c)
 	}
-	return nil
-} // ./UploadInventory
 
-func (s Service) inventoryItemBySku(sku string) (*InventoryItem, error) {
-	client := graphql.NewClient(s.endpoint)
-	// client.Log = func(s string) { log.Println(s) }
-	rq := graphql.NewRequest(fmt.Sprintf(`
-		{
-			inventoryItems(query: "sku:'%s'", first: 10) {
-				edges{
-					node{
-						id
-						sku
-						inventoryLevel(locationId: "%s"){
-							id
-							available
-						}
-					}
+	if opts.DryRun {
+		return rep, rep.WriteCSV(opts.Report)
+	}
+
+	fnis, err := os.OpenFile("not_in_shopify.csv", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer fnis.Close()
+	w := csv.NewWriter(fnis)
+	w.WriteAll(notInShopify)
+	err = w.Error()
+	if err != nil {
+		return nil, fmt.Errorf("not_in_shopify.csv: %w", err)
+	}
+
+	bak, err := openBackup(opts.Backup, rep.RunID)
+	if err != nil {
+		return nil, err
+	}
+	defer bak.Close()
+
+	// stop reports the changes from i on as not sent, e.g. when ctx is
+	// canceled, and still writes the report for the batches that were.
+	stop := func(i int, err error) (*InventoryReport, error) {
+		for _, c := range changes[i:] {
+			d := &rep.Diffs[c.row]
+			if d.Action == ActionSkip {
+				// already dropped from its batch
+				continue
+			}
+			d.Action, d.Reason, d.Delta = ActionSkip, "not sent: "+err.Error(), 0
+		}
+		if werr := rep.WriteCSV(opts.Report); werr != nil {
+			log.Log(LevelError, "report not written", "file", opts.Report, "error", werr)
+		}
+		return rep, err
+	}
+	refused := []*UserErrorsError{}
+	for start := 0; start < len(changes); start += inventoryBatchSize {
+		end := start + inventoryBatchSize
+		if end > len(changes) {
+			end = len(changes)
+		}
+		batch := changes[start:end]
+		// a change found stale this often is dropped from the batch
+		const maxStale = 3
+		staleCount := map[*quantityChange]int{}
+		for len(batch) > 0 {
+			err = bak.write(batch)
+			if err != nil {
+				return stop(start, err)
+			}
+			stale, rejected, err := s.setQuantities(ctx, batch)
+			if err != nil {
+				return stop(start, err)
+			}
+			if len(stale) == 0 && len(rejected) == 0 {
+				break
+			}
+			isStale := map[int]bool{}
+			for _, i := range stale {
+				isStale[i] = true
+			}
+			rest := []*quantityChange{}
+			for i, c := range batch {
+				if isStale[i] {
+					staleCount[c]++
+				}
+				d := &rep.Diffs[c.row]
+				switch {
+				case rejected[i] != nil:
+					refused = append(refused, rejected[i])
+					d.Action, d.Reason, d.Delta = ActionSkip, rejected[i].Error(), 0
+				case staleCount[c] >= maxStale:
+					d.Action, d.Reason, d.Delta = ActionSkip, "quantity kept changing", 0
+				default:
+					rest = append(rest, c)
+					continue
 				}
+				log.Log(LevelWarn, "sku skipped", "sku", c.item.Sku, "location", d.Location, "reason", d.Reason)
+			}
+			batch = rest
+			if len(batch) == 0 || len(stale) == 0 {
+				continue
+			}
+			// sold in the meantime, read the batch again
+			err = s.refreshLevels(ctx, batch)
+			if err != nil {
+				return stop(start, err)
 			}
 		}
-	`, sku, s.locationID))
-	type response struct {
-		InventoryItems struct {
-			Edges []struct {
-				Node InventoryItem `json:"node"`
-			} `json:"edges"`
-		} `json:"inventoryItems"`
-	}
-	rq.Header.Add("X-Shopify-Access-Token", s.accessToken)
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	var res response
-	err := client.Run(ctx, rq, &res)
+		for _, c := range batch {
+			// report what was actually replaced
+			d := &rep.Diffs[c.row]
+			d.Available = c.item.InventoryLevel.Available
+			d.Delta = c.quantity - d.Available
+			d.Action = ActionSet
+			if d.Delta == 0 {
+				d.Action = ActionUnchanged
+			}
+			log.Log(LevelInfo, "quantity set", "sku", c.item.Sku, "location", d.Location, "available", d.Available, "quantity", c.quantity, "delta", d.Delta)
+		}
+	}
+	err = rep.WriteCSV(opts.Report)
 	if err != nil {
-		return nil, err
+		return rep, err
+	}
+	if len(refused) > 0 {
+		return rep, &BatchError{Failures: refused}
 	}
-	if len(res.InventoryItems.Edges) > 1 {
-		fmt.Println("duplicate skus for ", sku)
+	return rep, nil
+} // ./UploadInventory
+
+// recordKeeper keeps the last record read, to copy skipped rows as they
+// were in the file.
+type recordKeeper struct {
+	r    solomon.RecordReader
+	last []string
+}
+
+func (k *recordKeeper) Read() ([]string, error) {
+	rec, err := k.r.Read()
+	k.last = rec
+	return rec, err
+} // ./Read
+
+func checkColumns(header []string, names ...string) error {
+	have := map[string]bool{}
+	for _, h := range header {
+		have[h] = true
 	}
-	if len(res.InventoryItems.Edges) == 0 {
-		return nil, nil
+	for _, n := range names {
+		if !have[n] {
+			return fmt.Errorf("no %s column", n)
+		}
 	}
-	ii := res.InventoryItems.Edges[0].Node
-	ii.apiMeta = s.apiMeta
-	return &ii, nil
-} // ./InventoryItemBySku
+	return nil
+} // ./checkColumns
 
 func FormatPhone(s string) string {
 	return strings.Replace(s, "+1", "", -1)
@@ -0,0 +1,162 @@
+package shopifytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// bulkOperation is the one bulk query a shop runs at a time. It reports
+// RUNNING on the first poll and COMPLETED after that.
+type bulkOperation struct {
+	id     string
+	polls  int
+	result []byte
+	count  int
+}
+
+func (b *bulkOperation) done() bool {
+	return b.polls > 1
+} // ./done
+
+// bulkOperationRunQuery runs the whole query right away. Like on Shopify,
+// connections selected on a node become lines of their own with a
+// __parentId, and connections inside lists are not supported.
+func bulkOperationRunQuery(s *Server, args map[string]interface{}) map[string]interface{} {
+	if s.bulk != nil && !s.bulk.done() {
+		return map[string]interface{}{
+			"bulkOperation": nil,
+			"userErrors": []UserError{{
+				Field:   []string{"query"},
+				Message: "A bulk query operation for this app and shop is already in progress",
+			}},
+		}
+	}
+	q, _ := args["query"].(string)
+	ff, err := rootFields(q, nil)
+	if err != nil {
+		return map[string]interface{}{
+			"bulkOperation": nil,
+			"userErrors":    []UserError{{Field: []string{"query"}, Message: err.Error()}},
+		}
+	}
+	s.bulkCount++
+	op := &bulkOperation{id: fmt.Sprintf("gid://shopify/BulkOperation/%d", s.bulkCount)}
+	buf := bytes.Buffer{}
+	for _, f := range ff {
+		for _, n := range search(s.resources[f.Name], f.Args) {
+			for _, line := range bulkLines(s.decorate(n, f.Body, nil), f.Body) {
+				data, _ := json.Marshal(line)
+				buf.Write(append(data, '\n'))
+				op.count++
+			}
+		}
+	}
+	op.result = buf.Bytes()
+	s.bulk = op
+	return map[string]interface{}{
+		"bulkOperation": map[string]interface{}{"id": op.id, "status": "CREATED"},
+		"userErrors":    []interface{}{},
+	}
+} // ./bulkOperationRunQuery
+
+// bulkLines splits a node into its own line followed by one line per node
+// of its selected connections.
+func bulkLines(n map[string]interface{}, body string) []map[string]interface{} {
+	keys := []string{}
+	for k := range n {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := []map[string]interface{}{n}
+	for _, k := range keys {
+		switch v := n[k].(type) {
+		case map[string]interface{}:
+			if !isConnection(v) {
+				continue
+			}
+			delete(n, k)
+			if !regexp.MustCompile(`\b` + k + `\b`).MatchString(body) {
+				continue
+			}
+			for _, c := range connectionNodes(v) {
+				c["__parentId"] = n["id"]
+				lines = append(lines, c)
+			}
+		case []interface{}:
+			for _, e := range v {
+				if e, ok := e.(map[string]interface{}); ok {
+					for ek, ev := range e {
+						if ev, ok := ev.(map[string]interface{}); ok && isConnection(ev) {
+							delete(e, ek)
+						}
+					}
+				}
+			}
+		}
+	}
+	return lines
+} // ./bulkLines
+
+func isConnection(v map[string]interface{}) bool {
+	_, nodes := v["nodes"]
+	_, edges := v["edges"]
+	return nodes || edges
+} // ./isConnection
+
+func connectionNodes(conn map[string]interface{}) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	nodes, _ := conn["nodes"].([]interface{})
+	for _, n := range nodes {
+		if n, ok := n.(map[string]interface{}); ok {
+			out = append(out, n)
+		}
+	}
+	edges, _ := conn["edges"].([]interface{})
+	for _, e := range edges {
+		e, _ := e.(map[string]interface{})
+		if n, ok := e["node"].(map[string]interface{}); ok {
+			out = append(out, n)
+		}
+	}
+	return out
+} // ./connectionNodes
+
+func (s *Server) currentBulkOperation() interface{} {
+	op := s.bulk
+	if op == nil {
+		return nil
+	}
+	op.polls++
+	if !op.done() {
+		return map[string]interface{}{"id": op.id, "status": "RUNNING", "objectCount": "0"}
+	}
+	var url interface{}
+	if len(op.result) > 0 {
+		url = fmt.Sprintf("%s/bulk/%d.jsonl", s.URL, legacyID(op.id))
+	}
+	return map[string]interface{}{
+		"id":             op.id,
+		"status":         "COMPLETED",
+		"errorCode":      nil,
+		"objectCount":    strconv.Itoa(op.count),
+		"url":            url,
+		"partialDataUrl": nil,
+	}
+} // ./currentBulkOperation
+
+func (s *Server) serveBulkResult(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := s.bulk
+	if op == nil || r.URL.Path != fmt.Sprintf("/bulk/%d.jsonl", legacyID(op.id)) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jsonl")
+	w.Write(op.result)
+} // ./serveBulkResult
@@ -0,0 +1,282 @@
+package shopifytest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// field is one root selection of an operation, e.g. `t0: tagsAdd(id: $id) {...}`.
+type field struct {
+	Alias string
+	Name  string
+	Args  map[string]interface{}
+	Body  string
+}
+
+func (f field) key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+} // ./key
+
+type scanner struct {
+	src  string
+	pos  int
+	vars map[string]interface{}
+}
+
+// rootFields returns the top level selections of a query or mutation
+// document with their arguments resolved against vars. It understands
+// just enough GraphQL for the documents this repo sends.
+func rootFields(doc string, vars map[string]interface{}) ([]field, error) {
+	sc := &scanner{src: doc, vars: vars}
+	// skip the operation header (keyword, name, variable definitions)
+	for sc.pos < len(sc.src) && sc.src[sc.pos] != '{' {
+		if sc.src[sc.pos] == '(' {
+			_, err := sc.group('(', ')')
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		sc.pos++
+	}
+	if sc.pos >= len(sc.src) {
+		return nil, fmt.Errorf("no selection set")
+	}
+	sc.pos++
+
+	ff := []field{}
+	for {
+		sc.skip()
+		if sc.pos >= len(sc.src) {
+			return nil, fmt.Errorf("unterminated selection set")
+		}
+		if sc.src[sc.pos] == '}' {
+			return ff, nil
+		}
+		f := field{}
+		f.Name = sc.name()
+		if f.Name == "" {
+			return nil, fmt.Errorf("unexpected %q at %d", sc.src[sc.pos], sc.pos)
+		}
+		sc.skip()
+		if sc.peek() == ':' {
+			sc.pos++
+			sc.skip()
+			f.Alias = f.Name
+			f.Name = sc.name()
+			sc.skip()
+		}
+		if sc.peek() == '(' {
+			args, err := sc.args()
+			if err != nil {
+				return nil, err
+			}
+			f.Args = args
+			sc.skip()
+		}
+		if sc.peek() == '{' {
+			body, err := sc.group('{', '}')
+			if err != nil {
+				return nil, err
+			}
+			f.Body = body
+		}
+		ff = append(ff, f)
+	}
+} // ./rootFields
+
+// nestedArgs finds the first `name(...)` inside a selection body and
+// returns its arguments.
+func nestedArgs(body, name string, vars map[string]interface{}) map[string]interface{} {
+	loc := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\s*\(`).FindStringIndex(body)
+	if loc == nil {
+		return nil
+	}
+	sc := &scanner{src: body, pos: loc[1] - 1, vars: vars}
+	args, err := sc.args()
+	if err != nil {
+		return nil
+	}
+	return args
+} // ./nestedArgs
+
+func (sc *scanner) peek() byte {
+	if sc.pos >= len(sc.src) {
+		return 0
+	}
+	return sc.src[sc.pos]
+} // ./peek
+
+func (sc *scanner) skip() {
+	for sc.pos < len(sc.src) {
+		c := sc.src[sc.pos]
+		if c == '#' {
+			for sc.pos < len(sc.src) && sc.src[sc.pos] != '\n' {
+				sc.pos++
+			}
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			sc.pos++
+			continue
+		}
+		return
+	}
+} // ./skip
+
+func (sc *scanner) name() string {
+	start := sc.pos
+	for sc.pos < len(sc.src) {
+		c := sc.src[sc.pos]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || sc.pos > start && c >= '0' && c <= '9' {
+			sc.pos++
+			continue
+		}
+		break
+	}
+	return sc.src[start:sc.pos]
+} // ./name
+
+// group returns the text between an opening delimiter at pos and its
+// matching close, leaving pos after the close.
+func (sc *scanner) group(open, close byte) (string, error) {
+	start := sc.pos + 1
+	depth := 0
+	for sc.pos < len(sc.src) {
+		c := sc.src[sc.pos]
+		switch {
+		case c == '"':
+			_, err := sc.str()
+			if err != nil {
+				return "", err
+			}
+			continue
+		case c == open:
+			depth++
+		case c == close:
+			depth--
+			if depth == 0 {
+				sc.pos++
+				return sc.src[start : sc.pos-1], nil
+			}
+		}
+		sc.pos++
+	}
+	return "", fmt.Errorf("unbalanced %c", open)
+} // ./group
+
+func (sc *scanner) str() (string, error) {
+	start := sc.pos
+	sc.pos++
+	for sc.pos < len(sc.src) {
+		switch sc.src[sc.pos] {
+		case '\\':
+			sc.pos += 2
+			continue
+		case '"':
+			sc.pos++
+			return strconv.Unquote(sc.src[start:sc.pos])
+		}
+		sc.pos++
+	}
+	return "", fmt.Errorf("unterminated string")
+} // ./str
+
+func (sc *scanner) args() (map[string]interface{}, error) {
+	sc.pos++ // (
+	args := map[string]interface{}{}
+	for {
+		sc.skip()
+		if sc.peek() == ')' {
+			sc.pos++
+			return args, nil
+		}
+		k := sc.name()
+		if k == "" {
+			return nil, fmt.Errorf("bad argument at %d", sc.pos)
+		}
+		sc.skip()
+		if sc.peek() != ':' {
+			return nil, fmt.Errorf("missing : after %s", k)
+		}
+		sc.pos++
+		v, err := sc.value()
+		if err != nil {
+			return nil, err
+		}
+		args[k] = v
+	}
+} // ./args
+
+func (sc *scanner) value() (interface{}, error) {
+	sc.skip()
+	switch c := sc.peek(); {
+	case c == '$':
+		sc.pos++
+		return sc.vars[sc.name()], nil
+	case c == '"':
+		return sc.str()
+	case c == '[':
+		sc.pos++
+		list := []interface{}{}
+		for {
+			sc.skip()
+			if sc.peek() == ']' {
+				sc.pos++
+				return list, nil
+			}
+			v, err := sc.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case c == '{':
+		sc.pos++
+		obj := map[string]interface{}{}
+		for {
+			sc.skip()
+			if sc.peek() == '}' {
+				sc.pos++
+				return obj, nil
+			}
+			k := sc.name()
+			sc.skip()
+			if sc.peek() != ':' {
+				return nil, fmt.Errorf("missing : after %s", k)
+			}
+			sc.pos++
+			v, err := sc.value()
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = v
+		}
+	case c == '-' || c >= '0' && c <= '9':
+		start := sc.pos
+		sc.pos++
+		for sc.pos < len(sc.src) && strings.IndexByte("0123456789.eE+-", sc.src[sc.pos]) >= 0 {
+			sc.pos++
+		}
+		return strconv.ParseFloat(sc.src[start:sc.pos], 64)
+	default:
+		word := sc.name()
+		switch word {
+		case "":
+			return nil, fmt.Errorf("unexpected %q at %d", c, sc.pos)
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// enum value
+		return word, nil
+	}
+} // ./value
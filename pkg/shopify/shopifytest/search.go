@@ -0,0 +1,233 @@
+package shopifytest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type term struct {
+	negate bool
+	field  string
+	op     string
+	value  string
+}
+
+// parseSearch splits a Shopify search query into its terms. Terms are
+// always ANDed; OR is not supported.
+func parseSearch(q string) []term {
+	tokens := []string{}
+	cur := strings.Builder{}
+	var quote rune
+	escaped := false
+	for _, r := range q {
+		switch {
+		case escaped:
+			escaped = false
+			cur.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			cur.WriteRune(r)
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			cur.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n':
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+
+	tt := []term{}
+	for _, tok := range tokens {
+		if tok == "AND" {
+			continue
+		}
+		t := term{}
+		if strings.HasPrefix(tok, "-") {
+			t.negate = true
+			tok = tok[1:]
+		}
+		sep := strings.Index(tok, ":")
+		if sep < 0 {
+			continue
+		}
+		t.field = tok[:sep]
+		v := tok[sep+1:]
+		for _, op := range []string{">=", "<=", ">", "<"} {
+			if strings.HasPrefix(v, op) {
+				t.op = op
+				v = v[len(op):]
+				break
+			}
+		}
+		t.value = unquoteSearch(v)
+		tt = append(tt, t)
+	}
+	return tt
+} // ./parseSearch
+
+func unquoteSearch(v string) string {
+	if len(v) >= 2 && (v[0] == '\'' || v[0] == '"') && v[len(v)-1] == v[0] {
+		v = v[1 : len(v)-1]
+	}
+	return strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`, `\:`, `:`, `\ `, ` `).Replace(v)
+} // ./unquoteSearch
+
+// matches reports whether node satisfies every term. Unknown fields are
+// ignored so that queries keep working against sparse fixtures.
+func matches(node map[string]interface{}, terms []term) bool {
+	for _, t := range terms {
+		ok, known := matchTerm(node, t)
+		if !known {
+			continue
+		}
+		if ok == t.negate {
+			return false
+		}
+	}
+	return true
+} // ./matches
+
+func matchTerm(node map[string]interface{}, t term) (bool, bool) {
+	switch t.field {
+	case "tag":
+		return hasTag(node, t.value), true
+	case "tag_not":
+		return !hasTag(node, t.value), true
+	case "sku":
+		// like Shopify, sku search is a case-insensitive prefix match
+		sku, _ := node["sku"].(string)
+		return strings.HasPrefix(strings.ToLower(sku), strings.ToLower(t.value)), true
+	case "status":
+		closed, _ := node["closed"].(bool)
+		switch t.value {
+		case "closed":
+			return closed, true
+		case "open":
+			return !closed, true
+		}
+		return true, true
+	case "test":
+		test, _ := node["test"].(bool)
+		return fmt.Sprint(test) == t.value, true
+	case "fulfillment_status":
+		v, _ := node["displayFulfillmentStatus"].(string)
+		return strings.EqualFold(v, t.value), true
+	case "financial_status":
+		v, _ := node["displayFinancialStatus"].(string)
+		return strings.EqualFold(v, t.value), true
+	case "id":
+		id, _ := node["id"].(string)
+		return compareInts(legacyID(id), legacyID(t.value), t.op), true
+	case "created_at", "updated_at", "closed_at", "processed_at":
+		return matchTime(node, camel(t.field), t), true
+	}
+	return false, false
+} // ./matchTerm
+
+func hasTag(node map[string]interface{}, tag string) bool {
+	tags, _ := node["tags"].([]interface{})
+	for _, v := range tags {
+		if s, ok := v.(string); ok && strings.EqualFold(s, tag) {
+			return true
+		}
+	}
+	return false
+} // ./hasTag
+
+func matchTime(node map[string]interface{}, key string, t term) bool {
+	raw, _ := node[key].(string)
+	got, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return false
+	}
+	want, err := time.Parse(time.RFC3339, t.value)
+	day := false
+	if err != nil {
+		want, err = time.Parse("2006-01-02", t.value)
+		if err != nil {
+			return false
+		}
+		day = true
+	}
+	switch t.op {
+	case ">":
+		if day {
+			return !got.Before(want.AddDate(0, 0, 1))
+		}
+		return got.After(want)
+	case ">=":
+		return !got.Before(want)
+	case "<":
+		return got.Before(want)
+	case "<=":
+		if day {
+			return got.Before(want.AddDate(0, 0, 1))
+		}
+		return !got.After(want)
+	}
+	if day {
+		return !got.Before(want) && got.Before(want.AddDate(0, 0, 1))
+	}
+	return got.Equal(want)
+} // ./matchTime
+
+// sortNodes orders nodes by a sortKey enum such as UPDATED_AT. Ties and
+// unknown keys fall back to ID order, as they do on Shopify.
+func sortNodes(nodes []map[string]interface{}, key string) {
+	field := camel(strings.ToLower(key))
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if field != "id" {
+			a, _ := time.Parse(time.RFC3339, fmt.Sprint(nodes[i][field]))
+			b, _ := time.Parse(time.RFC3339, fmt.Sprint(nodes[j][field]))
+			if !a.Equal(b) {
+				return a.Before(b)
+			}
+		}
+		return legacyID(fmt.Sprint(nodes[i]["id"])) < legacyID(fmt.Sprint(nodes[j]["id"]))
+	})
+} // ./sortNodes
+
+func compareInts(a, b int64, op string) bool {
+	switch op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return a == b
+} // ./compareInts
+
+func legacyID(gid string) int64 {
+	var n int64
+	sep := strings.LastIndex(gid, "/")
+	fmt.Sscanf(gid[sep+1:], "%d", &n)
+	return n
+} // ./legacyID
+
+func camel(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+} // ./camel
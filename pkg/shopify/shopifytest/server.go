@@ -0,0 +1,755 @@
+// Package shopifytest provides a fake Shopify Admin GraphQL API for tests.
+//
+// The server keeps canned resources (orders, customers, inventoryItems,
+// locations) in memory as the JSON nodes the client expects, including
+// aliased keys such as order_number. Queries are answered with whole nodes
+// regardless of the selection set, connections are paged with real
+// cursors, and mutations are recorded and applied to the stored nodes.
+// Bulk operations run at once and serve their JSONL result from the same
+// server.
+package shopifytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	resources  map[string][]map[string]interface{}
+	mutations  []Mutation
+	requests   int
+	throttle   int
+	userErrors map[string][]UserError
+	nodeErrors map[string][]UserError
+	bulk       *bulkOperation
+	bulkCount  int
+}
+
+type Mutation struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+type UserError struct {
+	Field   []string `json:"field"`
+	Message string   `json:"message"`
+	Code    string   `json:"code,omitempty"`
+}
+
+type mutationFunc func(s *Server, args map[string]interface{}) map[string]interface{}
+
+var mutationHandlers = map[string]mutationFunc{
+	"orderUpdate":               orderUpdate,
+	"customerUpdate":            customerUpdate,
+	"inventoryAdjustQuantities": inventoryAdjustQuantities,
+	"inventorySetQuantities":    inventorySetQuantities,
+	"tagsAdd":                   tagsAdd,
+	"tagsRemove":                tagsRemove,
+	"bulkOperationRunQuery":     bulkOperationRunQuery,
+}
+
+var singular = map[string]string{
+	"order":         "orders",
+	"customer":      "customers",
+	"product":       "products",
+	"inventoryItem": "inventoryItems",
+	"location":      "locations",
+}
+
+func NewServer() *Server {
+	s := &Server{
+		resources:  map[string][]map[string]interface{}{},
+		userErrors: map[string][]UserError{},
+		nodeErrors: map[string][]UserError{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+} // ./NewServer
+
+// Load appends the nodes of a JSON array file to a resource.
+func (s *Server) Load(resource, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var nodes []map[string]interface{}
+	err = json.Unmarshal(data, &nodes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	s.Add(resource, nodes...)
+	return nil
+} // ./Load
+
+func (s *Server) Add(resource string, nodes ...map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[resource] = append(s.resources[resource], nodes...)
+} // ./Add
+
+// Find returns a copy of the stored node with the given id.
+func (s *Server) Find(resource, id string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.find(resource, id)
+	if n == nil {
+		return nil
+	}
+	return clone(n)
+} // ./Find
+
+// SetQuantity changes a named quantity of the inventory item at a
+// location behind the client's back, e.g. to simulate a sale.
+func (s *Server) SetQuantity(itemID, locationID, name string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lvl := s.level(itemID, locationID); lvl != nil {
+		setQuantity(lvl, name, n)
+	}
+} // ./SetQuantity
+
+func (s *Server) Mutations() []Mutation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mutation{}, s.mutations...)
+} // ./Mutations
+
+// Requests returns the number of GraphQL requests received, throttled
+// ones included.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+} // ./Requests
+
+// Throttle answers the next n requests with a THROTTLED error.
+func (s *Server) Throttle(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttle = n
+} // ./Throttle
+
+// FailMutation makes every call to the named mutation return errs as
+// userErrors without applying it. Calling it without errs clears it.
+func (s *Server) FailMutation(name string, errs ...UserError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(errs) == 0 {
+		delete(s.userErrors, name)
+		return
+	}
+	s.userErrors[name] = errs
+} // ./FailMutation
+
+// FailNode makes every mutation of the node with the given id return errs
+// as userErrors without applying it, and inventorySetQuantities refuse
+// the quantities of an inventory item with that id, with each error's
+// field under input.quantities.<index>. Calling it without errs clears it.
+func (s *Server) FailNode(id string, errs ...UserError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(errs) == 0 {
+		delete(s.nodeErrors, id)
+		return
+	}
+	s.nodeErrors[id] = errs
+} // ./FailNode
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/bulk/") {
+		s.serveBulkResult(w, r)
+		return
+	}
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/graphql.json") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("X-Shopify-Access-Token") == "" {
+		http.Error(w, `{"errors":"[API] Invalid API key or access token"}`, http.StatusUnauthorized)
+		return
+	}
+	var body struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.throttle > 0 {
+		s.throttle--
+		writeJSON(w, map[string]interface{}{
+			"errors": []interface{}{map[string]interface{}{
+				"message":    "Throttled",
+				"extensions": map[string]interface{}{"code": "THROTTLED"},
+			}},
+			"extensions": cost(0),
+		})
+		return
+	}
+
+	ff, err := rootFields(body.Query, body.Variables)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	mutation := strings.HasPrefix(strings.TrimSpace(body.Query), "mutation")
+	data := map[string]interface{}{}
+	for _, f := range ff {
+		var v interface{}
+		if mutation {
+			v, err = s.mutate(f)
+		} else {
+			v, err = s.query(f, body.Variables)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		data[f.key()] = v
+	}
+	writeJSON(w, map[string]interface{}{
+		"data":       data,
+		"extensions": cost(990),
+	})
+} // ./serveHTTP
+
+func (s *Server) query(f field, vars map[string]interface{}) (interface{}, error) {
+	if f.Name == "fulfillment" {
+		id, _ := f.Args["id"].(string)
+		n := s.fulfillment(id)
+		if n == nil {
+			return nil, nil
+		}
+		return s.decorate(n, f.Body, vars), nil
+	}
+	if resource, ok := singular[f.Name]; ok {
+		id, _ := f.Args["id"].(string)
+		n := s.find(resource, id)
+		if n == nil {
+			return nil, nil
+		}
+		return s.decorate(n, f.Body, vars), nil
+	}
+	if f.Name == "currentBulkOperation" {
+		return s.currentBulkOperation(), nil
+	}
+	nodes, ok := s.resources[f.Name]
+	if !ok {
+		if _, known := map[string]bool{"orders": true, "customers": true, "inventoryItems": true, "locations": true, "products": true}[f.Name]; !known {
+			return nil, fmt.Errorf("field '%s' doesn't exist on type 'QueryRoot'", f.Name)
+		}
+	}
+
+	matched := search(nodes, f.Args)
+
+	first := toInt(f.Args["first"])
+	if first <= 0 {
+		return nil, fmt.Errorf("you must provide one of first or last")
+	}
+	if first > 250 {
+		return nil, fmt.Errorf("first: must be at most 250")
+	}
+	start := 0
+	if after, ok := f.Args["after"].(string); ok && after != "" {
+		i, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		start = i + 1
+	}
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := start + first
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	edges := []interface{}{}
+	page := []interface{}{}
+	for i := start; i < end; i++ {
+		n := s.decorate(matched[i], f.Body, vars)
+		edges = append(edges, map[string]interface{}{
+			"cursor": encodeCursor(i),
+			"node":   n,
+		})
+		page = append(page, n)
+	}
+	pageInfo := map[string]interface{}{
+		"hasNextPage":     end < len(matched),
+		"hasPreviousPage": start > 0,
+		"startCursor":     nil,
+		"endCursor":       nil,
+	}
+	if end > start {
+		pageInfo["startCursor"] = encodeCursor(start)
+		pageInfo["endCursor"] = encodeCursor(end - 1)
+	}
+	return map[string]interface{}{
+		"edges":    edges,
+		"nodes":    page,
+		"pageInfo": pageInfo,
+	}, nil
+} // ./query
+
+// search returns the nodes matching the query, sortKey and reverse
+// arguments of a connection field.
+func search(nodes []map[string]interface{}, args map[string]interface{}) []map[string]interface{} {
+	q, _ := args["query"].(string)
+	terms := parseSearch(q)
+	matched := []map[string]interface{}{}
+	for _, n := range nodes {
+		if matches(n, terms) {
+			matched = append(matched, n)
+		}
+	}
+
+	if key, _ := args["sortKey"].(string); key != "" {
+		sortNodes(matched, key)
+	}
+	if rev, _ := args["reverse"].(bool); rev {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+	}
+	return matched
+} // ./search
+
+// decorate resolves the few nested fields that take arguments.
+func (s *Server) decorate(n map[string]interface{}, body string, vars map[string]interface{}) map[string]interface{} {
+	out := clone(n)
+	if args := nestedArgs(body, "inventoryLevel", vars); args != nil {
+		locationID, _ := args["locationId"].(string)
+		out["inventoryLevel"] = nil
+		for _, lvl := range levels(n) {
+			loc, _ := lvl["location"].(map[string]interface{})
+			if loc != nil && loc["id"] == locationID {
+				out["inventoryLevel"] = clone(lvl)
+			}
+		}
+	}
+	if args := nestedArgs(body, "inventoryLevels", vars); args != nil {
+		if conn, ok := out["inventoryLevels"].(map[string]interface{}); ok {
+			out["inventoryLevels"] = pageNested(conn, args)
+		}
+	}
+	if args := nestedArgs(body, "fulfillmentLineItems", vars); args != nil {
+		if conn, ok := out["fulfillmentLineItems"].(map[string]interface{}); ok {
+			out["fulfillmentLineItems"] = pageNested(conn, args)
+		}
+		ff, _ := out["fulfillments"].([]interface{})
+		for _, f := range ff {
+			f, _ := f.(map[string]interface{})
+			if conn, ok := f["fulfillmentLineItems"].(map[string]interface{}); ok {
+				f["fulfillmentLineItems"] = pageNested(conn, args)
+			}
+		}
+	}
+	return out
+} // ./decorate
+
+// pageNested pages the nodes of a connection nested in a stored node, such
+// as the fulfillment line items of an order. The connection is stored
+// with either nodes or edges and the page has both.
+func pageNested(conn map[string]interface{}, args map[string]interface{}) map[string]interface{} {
+	nodes, _ := conn["nodes"].([]interface{})
+	if edges, ok := conn["edges"].([]interface{}); ok {
+		nodes = []interface{}{}
+		for _, e := range edges {
+			e, _ := e.(map[string]interface{})
+			nodes = append(nodes, e["node"])
+		}
+	}
+	start := 0
+	if after, ok := args["after"].(string); ok && after != "" {
+		i, err := decodeCursor(after)
+		if err == nil {
+			start = i + 1
+		}
+	}
+	if start > len(nodes) {
+		start = len(nodes)
+	}
+	end := len(nodes)
+	if first := toInt(args["first"]); first > 0 && start+first < end {
+		end = start + first
+	}
+	pageInfo := map[string]interface{}{
+		"hasNextPage": end < len(nodes),
+		"endCursor":   nil,
+	}
+	if end > start {
+		pageInfo["endCursor"] = encodeCursor(end - 1)
+	}
+	edges := []interface{}{}
+	for i := start; i < end; i++ {
+		edges = append(edges, map[string]interface{}{"cursor": encodeCursor(i), "node": nodes[i]})
+	}
+	return map[string]interface{}{
+		"edges":    edges,
+		"nodes":    nodes[start:end],
+		"pageInfo": pageInfo,
+	}
+} // ./pageNested
+
+// fulfillment finds a fulfillment among the stored orders.
+func (s *Server) fulfillment(id string) map[string]interface{} {
+	for _, o := range s.resources["orders"] {
+		ff, _ := o["fulfillments"].([]interface{})
+		for _, f := range ff {
+			if f, ok := f.(map[string]interface{}); ok && f["id"] == id {
+				return f
+			}
+		}
+	}
+	return nil
+} // ./fulfillment
+
+func (s *Server) mutate(f field) (interface{}, error) {
+	s.mutations = append(s.mutations, Mutation{Name: f.Name, Args: f.Args})
+	if errs, ok := s.userErrors[f.Name]; ok {
+		return map[string]interface{}{"userErrors": errs}, nil
+	}
+	if errs, ok := s.nodeErrors[targetID(f.Args)]; ok {
+		return map[string]interface{}{"userErrors": errs}, nil
+	}
+	h, ok := mutationHandlers[f.Name]
+	if !ok {
+		return nil, fmt.Errorf("field '%s' doesn't exist on type 'Mutation'", f.Name)
+	}
+	payload := h(s, f.Args)
+	if _, ok := payload["userErrors"]; !ok {
+		payload["userErrors"] = []UserError{}
+	}
+	return payload, nil
+} // ./mutate
+
+func orderUpdate(s *Server, args map[string]interface{}) map[string]interface{} {
+	input, _ := args["input"].(map[string]interface{})
+	id, _ := input["id"].(string)
+	o := s.find("orders", id)
+	if o == nil {
+		return notFound("id")
+	}
+	if tags, ok := input["tags"]; ok {
+		o["tags"] = tags
+	}
+	return map[string]interface{}{"order": clone(o)}
+} // ./orderUpdate
+
+func tagsAdd(s *Server, args map[string]interface{}) map[string]interface{} {
+	id, _ := args["id"].(string)
+	n := s.node(id)
+	if n == nil {
+		return notFound("id")
+	}
+	tags, _ := n["tags"].([]interface{})
+	add, _ := args["tags"].([]interface{})
+	for _, t := range add {
+		tag, _ := t.(string)
+		if !hasTag(n, tag) {
+			tags = append(tags, tag)
+			n["tags"] = tags
+			touch(n)
+		}
+	}
+	return map[string]interface{}{"node": map[string]interface{}{"id": id}}
+} // ./tagsAdd
+
+func tagsRemove(s *Server, args map[string]interface{}) map[string]interface{} {
+	id, _ := args["id"].(string)
+	n := s.node(id)
+	if n == nil {
+		return notFound("id")
+	}
+	tags, _ := n["tags"].([]interface{})
+	remove, _ := args["tags"].([]interface{})
+	kept := []interface{}{}
+	for _, t := range tags {
+		tag, _ := t.(string)
+		drop := false
+		for _, r := range remove {
+			if r, _ := r.(string); strings.EqualFold(r, tag) {
+				drop = true
+			}
+		}
+		if !drop {
+			kept = append(kept, tag)
+		}
+	}
+	if len(kept) != len(tags) {
+		n["tags"] = kept
+		touch(n)
+	}
+	return map[string]interface{}{"node": map[string]interface{}{"id": id}}
+} // ./tagsRemove
+
+// touch moves the updatedAt of n, if it has one, to now as Shopify does
+// on every change.
+func touch(n map[string]interface{}) {
+	if _, ok := n["updatedAt"]; ok {
+		n["updatedAt"] = time.Now().UTC().Format(time.RFC3339)
+	}
+} // ./touch
+
+func customerUpdate(s *Server, args map[string]interface{}) map[string]interface{} {
+	input, _ := args["input"].(map[string]interface{})
+	id, _ := input["id"].(string)
+	c := s.find("customers", id)
+	if c == nil {
+		return notFound("id")
+	}
+	mfs, _ := input["metafields"].([]interface{})
+	for i, v := range mfs {
+		mf, _ := v.(map[string]interface{})
+		key, _ := mf["key"].(string)
+		mfID, _ := mf["id"].(string)
+		if mfID == "" {
+			mfID = fmt.Sprintf("gid://shopify/Metafield/%s%d", strings.TrimPrefix(id, "gid://shopify/Customer/"), i)
+		}
+		c[key] = map[string]interface{}{
+			"id":    mfID,
+			"value": mf["value"],
+		}
+	}
+	return map[string]interface{}{"customer": clone(c)}
+} // ./customerUpdate
+
+func inventoryAdjustQuantities(s *Server, args map[string]interface{}) map[string]interface{} {
+	input, _ := args["input"].(map[string]interface{})
+	name, _ := input["name"].(string)
+	changes, _ := input["changes"].([]interface{})
+	out := []interface{}{}
+	for i, c := range changes {
+		c, _ := c.(map[string]interface{})
+		itemID, _ := c["inventoryItemId"].(string)
+		locationID, _ := c["locationId"].(string)
+		lvl := s.level(itemID, locationID)
+		if lvl == nil {
+			return notFound("input", "changes", strconv.Itoa(i), "locationId")
+		}
+		delta := toInt(c["delta"])
+		after := quantity(lvl, name) + delta
+		setQuantity(lvl, name, after)
+		out = append(out, map[string]interface{}{"name": name, "delta": delta, "quantityAfterChange": after})
+	}
+	return adjustmentGroup(input, out)
+} // ./inventoryAdjustQuantities
+
+func inventorySetQuantities(s *Server, args map[string]interface{}) map[string]interface{} {
+	input, _ := args["input"].(map[string]interface{})
+	name, _ := input["name"].(string)
+	qq, _ := input["quantities"].([]interface{})
+	// like Shopify, check every quantity before changing anything
+	levels := []map[string]interface{}{}
+	errs := []UserError{}
+	for i, q := range qq {
+		q, _ := q.(map[string]interface{})
+		itemID, _ := q["inventoryItemId"].(string)
+		locationID, _ := q["locationId"].(string)
+		field := []string{"input", "quantities", strconv.Itoa(i)}
+		for _, e := range s.nodeErrors[itemID] {
+			e.Field = append(append([]string{}, field...), e.Field...)
+			errs = append(errs, e)
+		}
+		lvl := s.level(itemID, locationID)
+		if lvl == nil {
+			errs = append(errs, UserError{Field: append(field, "locationId"), Message: "not found"})
+			continue
+		}
+		if cmp, ok := q["compareQuantity"]; ok && cmp != nil && toInt(cmp) != quantity(lvl, name) {
+			errs = append(errs, UserError{
+				Field:   append(field, "compareQuantity"),
+				Message: "The specified compare quantity does not match the current quantity.",
+				Code:    "COMPARE_QUANTITY_STALE",
+			})
+		}
+		levels = append(levels, lvl)
+	}
+	if len(errs) > 0 {
+		return map[string]interface{}{"userErrors": errs}
+	}
+	out := []interface{}{}
+	for i, q := range qq {
+		q, _ := q.(map[string]interface{})
+		before := quantity(levels[i], name)
+		after := toInt(q["quantity"])
+		setQuantity(levels[i], name, after)
+		out = append(out, map[string]interface{}{"name": name, "delta": after - before, "quantityAfterChange": after})
+	}
+	return adjustmentGroup(input, out)
+} // ./inventorySetQuantities
+
+func adjustmentGroup(input map[string]interface{}, changes []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"inventoryAdjustmentGroup": map[string]interface{}{
+			"reason":  input["reason"],
+			"changes": changes,
+		},
+	}
+} // ./adjustmentGroup
+
+func (s *Server) find(resource, id string) map[string]interface{} {
+	for _, n := range s.resources[resource] {
+		if n["id"] == id {
+			return n
+		}
+	}
+	return nil
+} // ./find
+
+// node finds a stored node of any resource by its gid.
+func (s *Server) node(id string) map[string]interface{} {
+	parts := strings.Split(strings.TrimPrefix(id, "gid://shopify/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		return nil
+	}
+	typ := strings.ToLower(parts[0][:1]) + parts[0][1:]
+	return s.find(singular[typ], id)
+} // ./node
+
+// targetID returns the id a mutation's arguments point at, if any.
+func targetID(args map[string]interface{}) string {
+	if id, ok := args["id"].(string); ok {
+		return id
+	}
+	input, _ := args["input"].(map[string]interface{})
+	id, _ := input["id"].(string)
+	return id
+} // ./targetID
+
+// level returns the stored level of an inventory item at a location.
+func (s *Server) level(itemID, locationID string) map[string]interface{} {
+	item := s.find("inventoryItems", itemID)
+	for _, lvl := range levels(item) {
+		loc, _ := lvl["location"].(map[string]interface{})
+		if loc != nil && loc["id"] == locationID {
+			return lvl
+		}
+	}
+	return nil
+} // ./level
+
+// quantity returns the named quantity of a stored inventory level.
+func quantity(lvl map[string]interface{}, name string) int {
+	qq, _ := lvl["quantities"].([]interface{})
+	for _, q := range qq {
+		q, _ := q.(map[string]interface{})
+		if q["name"] == name {
+			return toInt(q["quantity"])
+		}
+	}
+	return 0
+} // ./quantity
+
+// setQuantity changes a named quantity. Like on Shopify, changing
+// available moves on_hand by the same amount.
+func setQuantity(lvl map[string]interface{}, name string, n int) {
+	if name == "available" {
+		setQuantity(lvl, "on_hand", quantity(lvl, "on_hand")+n-quantity(lvl, name))
+	}
+	qq, _ := lvl["quantities"].([]interface{})
+	for _, q := range qq {
+		q, _ := q.(map[string]interface{})
+		if q["name"] == name {
+			q["quantity"] = n
+			return
+		}
+	}
+	lvl["quantities"] = append(qq, map[string]interface{}{"name": name, "quantity": n})
+} // ./setQuantity
+
+// levels returns the stored inventory level nodes of an inventory item.
+func levels(item map[string]interface{}) []map[string]interface{} {
+	conn, _ := item["inventoryLevels"].(map[string]interface{})
+	edges, _ := conn["edges"].([]interface{})
+	ll := []map[string]interface{}{}
+	for _, e := range edges {
+		edge, _ := e.(map[string]interface{})
+		if n, ok := edge["node"].(map[string]interface{}); ok {
+			ll = append(ll, n)
+		}
+	}
+	return ll
+} // ./levels
+
+func notFound(field ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"userErrors": []UserError{{Field: field, Message: "not found"}},
+	}
+} // ./notFound
+
+func cost(available int) map[string]interface{} {
+	return map[string]interface{}{
+		"cost": map[string]interface{}{
+			"requestedQueryCost": 10,
+			"actualQueryCost":    10,
+			"throttleStatus": map[string]interface{}{
+				"maximumAvailable":   1000,
+				"currentlyAvailable": available,
+				"restoreRate":        1000,
+			},
+		},
+	}
+} // ./cost
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+} // ./writeJSON
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, map[string]interface{}{
+		"errors": []interface{}{map[string]interface{}{"message": err.Error()}},
+	})
+} // ./writeError
+
+func encodeCursor(i int) string {
+	return base64.StdEncoding.EncodeToString([]byte("cursor:" + strconv.Itoa(i)))
+} // ./encodeCursor
+
+func decodeCursor(c string) (int, error) {
+	data, err := base64.StdEncoding.DecodeString(c)
+	if err != nil || !strings.HasPrefix(string(data), "cursor:") {
+		return 0, fmt.Errorf("invalid cursor %q", c)
+	}
+	return strconv.Atoi(strings.TrimPrefix(string(data), "cursor:"))
+} // ./decodeCursor
+
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+	return 0
+} // ./toInt
+
+// clone deep copies a JSON-shaped value so callers can't alias stored nodes.
+func clone(n map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(n)
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	return out
+} // ./clone
@@ -0,0 +1,103 @@
+package shopify
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrAmbiguousSku is returned when a SKU is shared by several variants
+// and nothing says which one is meant.
+var ErrAmbiguousSku = errors.New("SKU is shared by several variants")
+
+// DuplicateSku is a SKU shared by more than one inventory item.
+type DuplicateSku struct {
+	Sku   string
+	Items []InventoryItem
+}
+
+// DuplicateSkus audits the catalog for SKUs shared by several variants,
+// sorted by SKU.
+func (s Service) DuplicateSkus(ctx context.Context) ([]DuplicateSku, error) {
+	items, err := s.allInventoryItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dd := []DuplicateSku{}
+	for sku, ii := range skuIndex(items, false) {
+		if len(ii) > 1 {
+			dd = append(dd, DuplicateSku{Sku: sku, Items: ii})
+		}
+	}
+	sort.Slice(dd, func(i, j int) bool { return dd[i].Sku < dd[j].Sku })
+	return dd, nil
+} // ./DuplicateSkus
+
+// skuKey is what two SKUs must share to be the same. Surrounding spaces
+// never count; loose ignores case too.
+func skuKey(sku string, loose bool) string {
+	sku = strings.TrimSpace(sku)
+	if loose {
+		sku = strings.ToLower(sku)
+	}
+	return sku
+} // ./skuKey
+
+// SkuResolutions says which inventory item wins for a duplicated SKU.
+type SkuResolutions map[string]string
+
+// LoadSkuResolutions reads a CSV of sku,winner lines where winner is the
+// inventory item or product variant ID to use. A missing file resolves
+// nothing.
+func LoadSkuResolutions(path string) (SkuResolutions, error) {
+	res := SkuResolutions{}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		sku, winner := strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		if sku == "sku" {
+			continue
+		}
+		res[sku] = winner
+	}
+	return res, nil
+} // ./LoadSkuResolutions
+
+// Pick returns the one item meant by sku among matches, or
+// ErrAmbiguousSku.
+func (res SkuResolutions) Pick(sku string, matches []InventoryItem) (*InventoryItem, error) {
+	if len(matches) == 1 {
+		return &matches[0], nil
+	}
+	winner, ok := res[sku]
+	if !ok {
+		return nil, fmt.Errorf("%s on %d variants: %w", sku, len(matches), ErrAmbiguousSku)
+	}
+	for i := range matches {
+		if matches[i].ID == winner || matches[i].Variant.ID == winner {
+			return &matches[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s: resolution %s is none of its %d variants: %w", sku, winner, len(matches), ErrAmbiguousSku)
+} // ./Pick
@@ -0,0 +1,185 @@
+package shopify
+
+import (
+	"fmt"
+	"strings"
+
+	"atlasbilliards.com/pkg/date"
+	"atlasbilliards.com/pkg/solomon"
+)
+
+// SolomonMember maps a customer to a row of MEMBERS.txt, identified by
+// its full gid as the members export always has.
+func SolomonMember(c Customer) solomon.Member {
+	tags := map[string]bool{}
+	for _, v := range c.Tags {
+		tags[v] = true
+	}
+	priceClass := "Retail"
+	if _, ok := tags["wholesale"]; ok {
+		priceClass = "Wholesale"
+	}
+	a := MailingAddress{}
+	if c.DefaultAddress != nil {
+		a = *c.DefaultAddress
+	}
+	company := ""
+	if a.Company == "NULL" || a.Company == "Null" || a.Company == "null" {
+		company = ""
+	} else {
+		company = a.Company
+	}
+	return solomon.Member{
+		MemberID:        c.ID,
+		CustID:          c.CustomerNumber.Value,
+		Email:           c.Email,
+		FirstName:       c.FirstName,
+		LastName:        c.LastName,
+		CompanyName:     company,
+		Address1:        a.Address1,
+		Address2:        ReplaceNull(a.Address2),
+		City:            a.City,
+		StateCode:       a.State,
+		Zip:             a.Zip,
+		CountryCode:     a.Country,
+		Region:          a.Region,
+		Phone:           FormatPhone(c.Phone),
+		Cell:            FormatPhone(c.Phone),
+		PriceClass:      priceClass,
+		ApprovalPending: "Yes",
+		DateCreated:     date.ToSolomonDateFormat(c.CreatedAt),
+	}
+} // ./SolomonMember
+
+// SolomonStoreOrder maps an order to a row of STORE_ORDERS.txt.
+func SolomonStoreOrder(o Order) solomon.StoreOrder {
+	c := o.Customer
+	billA := o.BillingAddress
+	shipA := o.ShippingAddress
+	if o.BillingAddressMatchesShippingAddress {
+		billA = shipA
+	}
+	return solomon.StoreOrder{
+		OrderID:           legacyID(o.ID),
+		CustID:            c.CustomerNumber.Value,
+		OrderNr:           solomonOrderNumber(o.OrderNumber),
+		AdminCode:         "WEB",
+		MemberID:          legacyID(c.ID),
+		BillingFirstName:  billA.FirstName,
+		BillingLastName:   billA.LastName,
+		BillingCompany:    billA.Company,
+		BillingAddress1:   billA.Address1,
+		BillingAddress2:   billA.Address2,
+		BillingCity:       billA.City,
+		BillingState:      billA.State,
+		BillingCountry:    billA.Country,
+		BillingZip:        billA.Zip,
+		BillingPhone:      FormatPhone(billA.Phone),
+		ShippingFirstName: shipA.FirstName,
+		ShippingLastName:  shipA.LastName,
+		ShippingCompany:   shipA.Company,
+		ShippingAddress1:  shipA.Address1,
+		ShippingAddress2:  shipA.Address2,
+		ShippingCity:      shipA.City,
+		ShippingState:     shipA.State,
+		ShippingCountry:   shipA.Country,
+		ShippingZip:       shipA.Zip,
+		ShippingPhone:     FormatPhone(shipA.Phone),
+		ShippingCode:      "NA",
+		Terms:             "CC",
+		Email:             c.Email,
+		BaseSubtotal:      o.CurrentSubtotalPriceSet.PresentmentMoney.String(),
+		Subtotal:          o.CurrentSubtotalPriceSet.PresentmentMoney.String(),
+		TaxAmount:         o.CurrentTotalTaxSet.PresentmentMoney.String(),
+		ShippingAmount:    o.TotalShippingPriceSet.PresentmentMoney.String(),
+		Total:             o.TotalReceivedSet.PresentmentMoney.String(),
+		CreateDate:        date.ToSolomonDateFormat(o.CreatedAt),
+		ProcessDate:       date.ToSolomonDateFormat(o.CreatedAt), // TODO: TEMP
+		SettleDate:        date.ToSolomonDateFormat(o.ClosedAt),
+		ShippedDate:       date.ToSolomonDateFormat(o.CreatedAt), // TODO: TEMP
+	}
+} // ./SolomonStoreOrder
+
+// SolomonCartItems maps the fulfilled line items of an order to rows of
+// STORE_CART_ITEMS.txt.
+func SolomonCartItems(o Order) []solomon.StoreCartItem {
+	orderNumber := solomonOrderNumber(o.OrderNumber)
+	ci := []solomon.StoreCartItem{}
+	for _, l := range fulfilledItems(o) {
+		price := l.LineItem.DiscountedUnitPriceSet.PresentmentMoney.String()
+		ci = append(ci, solomon.StoreCartItem{
+			CartItemID:    legacyID(l.LineItem.ID),
+			OrderNr:       orderNumber,
+			ItemVariantID: "", // TODO: ITEM VARIANT ID
+			ItemPrice:     price,
+			SalePrice:     "0.00",
+			IsOnSale:      "False",
+			ItemNumber:    l.LineItem.Variant.Sku,
+			UnitOfMeasure: "Each",
+			ItemQuantity:  l.LineItem.CurrentQuantity,
+			ItemName:      l.LineItem.Variant.Title,
+			Weight:        fmt.Sprintf("%.4f", l.LineItem.Variant.Weight),
+			Price:         price,
+		})
+	}
+	return ci
+} // ./SolomonCartItems
+
+// fulfilledItems are the fulfillment line items of o. Bulk queries can't
+// read connections inside the fulfillments list, so for orders read in
+// bulk the line items still on the order stand in for them.
+func fulfilledItems(o Order) []FulfillmentLineItem {
+	ff := []FulfillmentLineItem{}
+	for _, f := range o.Fulfillments {
+		ff = append(ff, f.FulfillmentLineItems.Nodes...)
+	}
+	if len(ff) > 0 || len(o.Fulfillments) == 0 {
+		return ff
+	}
+	for _, l := range o.LineItems {
+		if l.CurrentQuantity > 0 {
+			ff = append(ff, FulfillmentLineItem{LineItem: l})
+		}
+	}
+	return ff
+} // ./fulfilledItems
+
+// SolomonInventoryQuantities maps an inventory item to one row of
+// "ABS Inventory Quantities.txt" per location it is stocked at.
+func SolomonInventoryQuantities(i InventoryItem, locations []Location) []solomon.InventoryQuantity {
+	qq := []solomon.InventoryQuantity{}
+	for _, loc := range locations {
+		lvl := i.InventoryLevels.At(loc.ID)
+		if lvl == nil {
+			// not stocked at this location
+			continue
+		}
+		qq = append(qq, solomon.InventoryQuantity{
+			InventoryID:   i.Variant.Sku,
+			Description:   i.Variant.DisplayName,
+			StockingUOM:   "EA",
+			PurchasingUOM: "EA",
+			SellingUOM:    "EA",
+			StatusCode:    "AC",
+			Quantity:      lvl.Available,
+			Location:      loc.Name,
+		})
+	}
+	return qq
+} // ./SolomonInventoryQuantities
+
+func solomonOrderNumber(orderNumber string) string {
+	if strings.HasPrefix(orderNumber, "#") {
+		orderNumber = strings.ReplaceAll(orderNumber, "#", "")
+		if !strings.HasPrefix(orderNumber, "130000") {
+			orderNumber = "130000" + orderNumber
+		}
+	}
+	return orderNumber
+} // ./solomonOrderNumber
+
+// legacyID returns the numeric tail of a gid://shopify/... ID.
+func legacyID(gid string) string {
+	sep := strings.Split(gid, "/")
+	return sep[len(sep)-1]
+} // ./legacyID
@@ -2,78 +2,133 @@ package shopify
 
 import (
 	"context"
-	"time"
+	"encoding/json"
+	"errors"
+	"fmt"
 
-	"github.com/machinebox/graphql"
+	"atlasbilliards.com/pkg/money"
 )
 
 type InventoryItem struct {
 	apiMeta
 	ID                string          `json:"id"`
 	Sku               string          `json:"sku"`
-	UnitCost          UnitCost        `json:"unitCost"`
+	UnitCost          money.Money     `json:"unitCost"`
 	DuplicateSkuCount int             `json:"duplicateSkuCount"`
 	Variant           Variant         `json:"variant"`
 	InventoryLevel    *InventoryLevel `json:"inventoryLevel"`
+	InventoryLevels   InventoryLevels `json:"inventoryLevels"`
 }
 
 type InventoryLevel struct {
-	ID        string `json:"id"`
-	Available int    `json:"available"`
+	ID        string
+	Available int
+	Location  Location
 }
 
-type UnitCost struct {
-	Amount       interface{} `json:"amount"`
-	CurrencyCode string      `json:"currencyCode"`
-}
+// UnmarshalJSON reads Available from the level's
+// quantities(names: ["available"]) list.
+func (l *InventoryLevel) UnmarshalJSON(data []byte) error {
+	var v struct {
+		ID         string `json:"id"`
+		Quantities []struct {
+			Name     string `json:"name"`
+			Quantity int    `json:"quantity"`
+		} `json:"quantities"`
+		Location Location `json:"location"`
+	}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	*l = InventoryLevel{ID: v.ID, Location: v.Location}
+	for _, q := range v.Quantities {
+		if q.Name == "available" {
+			l.Available = q.Quantity
+		}
+	}
+	return nil
+} // ./UnmarshalJSON
+
+type InventoryLevels []InventoryLevel
+
+func (ll *InventoryLevels) UnmarshalJSON(data []byte) error {
+	var conn struct {
+		Edges []struct {
+			Node InventoryLevel `json:"node"`
+		} `json:"edges"`
+		Nodes []InventoryLevel `json:"nodes"`
+	}
+	err := json.Unmarshal(data, &conn)
+	if err != nil {
+		return err
+	}
+	out := InventoryLevels(conn.Nodes)
+	if len(out) == 0 {
+		for _, e := range conn.Edges {
+			out = append(out, e.Node)
+		}
+	}
+	*ll = out
+	return nil
+} // ./UnmarshalJSON
 
-func (ii InventoryItem) SetQuantity(quantity int) error {
+// At returns the level stocked at locationID, or nil.
+func (ll InventoryLevels) At(locationID string) *InventoryLevel {
+	for i := range ll {
+		if ll[i].Location.ID == locationID {
+			return &ll[i]
+		}
+	}
+	return nil
+} // ./At
+
+// ErrQuantityChanged is returned by SetQuantity when the available
+// quantity moved since the item was read, e.g. because of a sale.
+var ErrQuantityChanged = errors.New("available quantity changed since it was read")
+
+// SetQuantity sets the available quantity at the item's InventoryLevel.
+// The write only happens if Shopify still holds the Available value the
+// level was read with, otherwise ErrQuantityChanged is returned and the
+// caller should read the level again.
+func (ii InventoryItem) SetQuantity(ctx context.Context, quantity int) error {
+	if ii.InventoryLevel == nil {
+		return fmt.Errorf("%s: no inventory level to set", ii.ID)
+	}
+	stale, refused, err := ii.setQuantities(ctx, []*quantityChange{{item: ii, quantity: quantity}})
+	if err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		return ErrQuantityChanged
+	}
+	if refused[0] != nil {
+		return refused[0]
+	}
 	return nil
 } // ./SetQuantity
 
-func (ii InventoryItem) UpdateQuantity(amountDelta int) error {
-	rq := graphql.NewRequest(`
-		mutation inventoryAdjustQuantity($input: InventoryAdjustQuantityInput!) {
-			inventoryAdjustQuantity(input: $input) {
-				inventoryLevel {
-					id
-					available
-					incoming
-					item {
-						id
-						sku
-					}
-					location {
-						id
-						name
-					}
-				}
-				userErrors {
-					field
-					message
-				}
-			}
-		}
-	`)
+func (ii InventoryItem) UpdateQuantity(ctx context.Context, amountDelta int) error {
+	rq := newRequest("inventory_adjust_quantities")
 	input := map[string]interface{}{
-		"inventoryLevelId": ii.InventoryLevel.ID,
-		"availableDelta":   amountDelta,
+		"name":   "available",
+		"reason": "correction",
+		"changes": []map[string]interface{}{{
+			"inventoryItemId": ii.ID,
+			"locationId":      ii.InventoryLevel.Location.ID,
+			"delta":           amountDelta,
+		}},
 	}
 	rq.Var("input", input)
-	rq.Header.Add("X-Shopify-Access-Token", ii.accessToken)
-	client := graphql.NewClient(ii.endpoint)
-	// client.Log = func(s string) { log.Println(s) }
 	type response struct {
-		InventoryAdjustQuantity struct {
-			InventoryLevel InventoryLevel `json:"inventoryLevel"`
-		} `json:"inventoryAdjustQuantity"`
+		InventoryAdjustQuantities struct {
+			UserErrors []UserErrors `json:"userErrors"`
+		} `json:"inventoryAdjustQuantities"`
 	}
 	var rs response
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	err := client.Run(ctx, rq, &rs)
+	err := ii.run(ctx, rq, &rs)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkUserErrors("inventoryAdjustQuantities", ii.ID, rs.InventoryAdjustQuantities.UserErrors)
 } // ./ UpdateQuantity
@@ -3,6 +3,8 @@ package shopify
 import (
 	"encoding/json"
 	"time"
+
+	"atlasbilliards.com/pkg/money"
 )
 
 type Order struct {
@@ -19,6 +21,7 @@ type Order struct {
 	CreatedAt                            time.Time      `json:"createdAt"`
 	ProcessedAt                          time.Time      `json:"processedAt"`
 	ClosedAt                             time.Time      `json:"closedAt"`
+	UpdatedAt                            time.Time      `json:"updatedAt"`
 	CurrentSubtotalPriceSet              PriceSet       `json:"currentSubtotalPriceSet"`
 	CurrentTotalTaxSet                   PriceSet       `json:"currentTotalTaxSet"`
 	TotalShippingPriceSet                PriceSet       `json:"totalShippingPriceSet"`
@@ -35,18 +38,15 @@ type Order struct {
 }
 
 type Fulfillment struct {
+	ID                   string `json:"id"`
 	FulfillmentLineItems struct {
-		Nodes []FulfillmentLineItem `json:"nodes"`
+		Nodes    []FulfillmentLineItem `json:"nodes"`
+		PageInfo PageInfo              `json:"pageInfo"`
 	} `json:"fulfillmentLineItems"`
 }
 
-type PresentmentMoney struct {
-	Amount      float64 `json:"amount,string"`
-	CurrentCode string  `json:"currencyCode"`
-}
-
 type PriceSet struct {
-	PresentmentMoney PresentmentMoney `json:"presentmentMoney"`
+	PresentmentMoney money.Money `json:"presentmentMoney"`
 }
 
 type PaymentTerms struct {
@@ -95,6 +95,7 @@ func (o *Order) UnmarshalJSON(data []byte) error {
 		CreatedAt                            time.Time      `json:"createdAt"`
 		ProcessedAt                          time.Time      `json:"processedAt"`
 		ClosedAt                             time.Time      `json:"closedAt"`
+		UpdatedAt                            time.Time      `json:"updatedAt"`
 		CurrentSubtotalPriceSet              PriceSet       `json:"currentSubtotalPriceSet"`
 		CurrentTotalTaxSet                   PriceSet       `json:"currentTotalTaxSet"`
 		TotalShippingPriceSet                PriceSet       `json:"totalShippingPriceSet"`
@@ -129,6 +130,7 @@ func (o *Order) UnmarshalJSON(data []byte) error {
 		CreatedAt:                            _o.CreatedAt,
 		ProcessedAt:                          _o.ProcessedAt,
 		ClosedAt:                             _o.ClosedAt,
+		UpdatedAt:                            _o.UpdatedAt,
 		CurrentSubtotalPriceSet:              _o.CurrentSubtotalPriceSet,
 		CurrentTotalTaxSet:                   _o.CurrentTotalTaxSet,
 		TotalShippingPriceSet:                _o.TotalShippingPriceSet,
@@ -1,16 +1,20 @@
 package shopify
 
+import "atlasbilliards.com/pkg/money"
+
 type Product struct {
+	ID     string `json:"id"`
 	Title  string `json:"title"`
 	Handle string `json:"handle"`
 }
 
 type Variant struct {
-	ID                string  `json:"id"`
-	DisplayName       string  `json:"displayName"`
-	Title             string  `json:"title"`
-	Sku               string  `json:"sku"`
-	Price             string  `json:"price"`
-	Weight            float64 `json:"weight"`
-	InventoryQuantity int     `json:"inventoryQuantity"`
+	ID                string      `json:"id"`
+	DisplayName       string      `json:"displayName"`
+	Title             string      `json:"title"`
+	Sku               string      `json:"sku"`
+	Price             money.Money `json:"price"`
+	Weight            float64     `json:"weight"`
+	InventoryQuantity int         `json:"inventoryQuantity"`
+	Product           *Product    `json:"product"`
 }
@@ -1,6 +1,78 @@
 package shopify
 
+import (
+	"fmt"
+	"strings"
+)
+
 type UserErrors struct {
-	Message string `json:"message"`
-	Field   string `json:"field"`
+	Message string   `json:"message"`
+	Field   []string `json:"field"`
+	Code    string   `json:"code"`
+}
+
+// String is the message prefixed by the field path and followed by the
+// code, when Shopify sent them, e.g. "input.quantities.0: Not stocked (NOT_STOCKED)".
+func (ue UserErrors) String() string {
+	s := ue.Message
+	if len(ue.Field) > 0 {
+		s = strings.Join(ue.Field, ".") + ": " + s
+	}
+	if ue.Code != "" {
+		s += " (" + ue.Code + ")"
+	}
+	return s
+} // ./String
+
+// UserErrorsError is returned when Shopify answers a mutation with
+// userErrors, i.e. refused it without the request failing.
+type UserErrorsError struct {
+	Mutation string
+	// ID is the node the mutation was for, if it was for one.
+	ID     string
+	Errors []UserErrors
+}
+
+func (e *UserErrorsError) Error() string {
+	msgs := []string{}
+	for _, ue := range e.Errors {
+		msgs = append(msgs, ue.String())
+	}
+	target := e.Mutation
+	if e.ID != "" {
+		target += " " + e.ID
+	}
+	return fmt.Sprintf("%s: %s", target, strings.Join(msgs, "; "))
+} // ./Error
+
+// HasCode reports whether any of the errors has code.
+func (e *UserErrorsError) HasCode(code string) bool {
+	for _, ue := range e.Errors {
+		if ue.Code == code {
+			return true
+		}
+	}
+	return false
+} // ./HasCode
+
+// checkUserErrors is a *UserErrorsError for ue, or nil when ue is empty.
+func checkUserErrors(mutation, id string, ue []UserErrors) error {
+	if len(ue) == 0 {
+		return nil
+	}
+	return &UserErrorsError{Mutation: mutation, ID: id, Errors: ue}
+} // ./checkUserErrors
+
+// BatchError is returned by the batch commands that go on past refused
+// mutations. Failures has one entry per refused mutation.
+type BatchError struct {
+	Failures []*UserErrorsError
 }
+
+func (e *BatchError) Error() string {
+	msgs := []string{}
+	for _, f := range e.Failures {
+		msgs = append(msgs, f.Error())
+	}
+	return fmt.Sprintf("%d mutations refused: %s", len(e.Failures), strings.Join(msgs, "; "))
+} // ./Error
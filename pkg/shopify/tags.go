@@ -0,0 +1,193 @@
+package shopify
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/machinebox/graphql"
+)
+
+// tagBatchSize is how many tagsAdd or tagsRemove calls go into one
+// request. Each costs 10 points, well under the 1000 point single query
+// limit.
+const tagBatchSize = 25
+
+// tagDocuments are the queries files of the tag mutations.
+var tagDocuments = map[string]string{
+	"tagsAdd":    "tags_add",
+	"tagsRemove": "tags_remove",
+}
+
+// TagFailure is a node that could not be tagged.
+type TagFailure struct {
+	ID    string
+	Error string
+	// Refused is set when Shopify refused the tags rather than the
+	// request failing. It is not kept by saveTagFailures.
+	Refused *UserErrorsError
+}
+
+// TagError is returned by AddTags and RemoveTags when some of the nodes
+// failed. The others were tagged.
+type TagError struct {
+	Mutation string
+	Failures []TagFailure
+}
+
+func (e *TagError) Error() string {
+	msgs := []string{}
+	for _, f := range e.Failures {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", f.ID, f.Error))
+	}
+	return fmt.Sprintf("%s failed for %d nodes: %s", e.Mutation, len(e.Failures), strings.Join(msgs, "; "))
+} // ./Error
+
+// AddTags adds tags to the nodes in ids, keeping the tags they already
+// have. Anything with tags works: orders, draft orders, customers,
+// products and articles.
+func (s Service) AddTags(ctx context.Context, ids []string, tags ...string) error {
+	return s.tagAll(ctx, "tagsAdd", ids, tags)
+} // ./AddTags
+
+// RemoveTags removes tags from the nodes in ids, keeping their other tags.
+func (s Service) RemoveTags(ctx context.Context, ids []string, tags ...string) error {
+	return s.tagAll(ctx, "tagsRemove", ids, tags)
+} // ./RemoveTags
+
+func (s Service) tagAll(ctx context.Context, mutation string, ids []string, tags []string) error {
+	failed, err := s.tagBatches(ctx, mutation, ids, tags)
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &TagError{Mutation: mutation, Failures: failed}
+	}
+	return nil
+} // ./tagAll
+
+// tagBatches sends mutation (tagsAdd or tagsRemove) for every node in ids
+// with batches of aliased calls. A failed request fails its whole batch;
+// the remaining batches are still sent. Only a cancelled ctx stops it
+// early, in which case every node not yet tagged is reported as failed.
+func (s Service) tagBatches(ctx context.Context, mutation string, ids []string, tags []string) ([]TagFailure, error) {
+	failed := []TagFailure{}
+	for start := 0; start < len(ids); start += tagBatchSize {
+		end := start + tagBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		rq := graphql.NewRequest(batchDocument(tagDocuments[mutation], len(batch), "tags"))
+		rq.Var("tags", tags)
+		for i, id := range batch {
+			rq.Var(batchVar("id", i), id)
+		}
+
+		var rs map[string]*struct {
+			Node *struct {
+				ID string `json:"id"`
+			} `json:"node"`
+			UserErrors []UserErrors `json:"userErrors"`
+		}
+		err := s.run(ctx, rq, &rs)
+		if ctx.Err() != nil {
+			// report the rest too so that the caller can retry them
+			for _, id := range ids[start:] {
+				failed = append(failed, TagFailure{ID: id, Error: ctx.Err().Error()})
+			}
+			return failed, ctx.Err()
+		}
+		for i, id := range batch {
+			r := rs[batchAlias(i)]
+			switch {
+			case err != nil:
+				failed = append(failed, TagFailure{ID: id, Error: err.Error()})
+			case r == nil:
+				failed = append(failed, TagFailure{ID: id, Error: "no result"})
+			case len(r.UserErrors) > 0:
+				msgs := []string{}
+				for _, ue := range r.UserErrors {
+					msgs = append(msgs, ue.Message)
+				}
+				failed = append(failed, TagFailure{
+					ID:      id,
+					Error:   strings.Join(msgs, "; "),
+					Refused: &UserErrorsError{Mutation: mutation, ID: id, Errors: r.UserErrors},
+				})
+			}
+		}
+	}
+	return failed, nil
+} // ./tagBatches
+
+// loadTagFailures reads a file written by saveTagFailures. A missing file
+// holds no failures.
+func loadTagFailures(path string) ([]TagFailure, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	ff := []TagFailure{}
+	for first := true; ; first = false {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if first && row[0] == "id" {
+			continue
+		}
+		ff = append(ff, TagFailure{ID: row[0], Error: row[1]})
+	}
+	return ff, nil
+} // ./loadTagFailures
+
+// saveTagFailures replaces the file at path with ff, or removes it when
+// there are none.
+func saveTagFailures(path string, ff []TagFailure) error {
+	if len(ff) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(tmp)
+	w.Write([]string{"id", "error"})
+	for _, f := range ff {
+		w.Write([]string{f.ID, f.Error})
+	}
+	w.Flush()
+	err = w.Error()
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+} // ./saveTagFailures
@@ -0,0 +1,329 @@
+package shopify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 6
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Transport sits between the graphql client and the network. It keeps
+// track of the cost bucket Shopify reports in extensions.cost, waits for
+// it to refill before sending a request that would be throttled, and
+// retries throttled requests as well as transient failures of queries.
+// Mutations are only retried when Shopify did not execute them (throttled).
+type Transport struct {
+	Base       http.RoundTripper
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Logger gets the cost of every query at LevelDebug and each retry at
+	// LevelWarn. Nil logs nothing.
+	Logger Logger
+
+	mu       sync.Mutex
+	bucket   throttleStatus
+	seen     time.Time
+	lastCost float64
+}
+
+type throttleStatus struct {
+	MaximumAvailable   float64 `json:"maximumAvailable"`
+	CurrentlyAvailable float64 `json:"currentlyAvailable"`
+	RestoreRate        float64 `json:"restoreRate"`
+}
+
+type queryCost struct {
+	RequestedQueryCost float64        `json:"requestedQueryCost"`
+	ThrottleStatus     throttleStatus `json:"throttleStatus"`
+}
+
+type costEnvelope struct {
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+	Extensions struct {
+		Cost *queryCost `json:"cost"`
+	} `json:"extensions"`
+}
+
+// RetryError is returned once a request has used up all of its retries.
+type RetryError struct {
+	Attempts   int
+	StatusCode int
+	Throttled  bool
+	Err        error
+}
+
+func (e *RetryError) Error() string {
+	reason := "request failed"
+	if e.Throttled {
+		reason = "throttled"
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("shopify: %s after %d attempts: %s", reason, e.Attempts, e.Err.Error())
+	}
+	return fmt.Sprintf("shopify: %s after %d attempts: status %d", reason, e.Attempts, e.StatusCode)
+} // ./Error
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+} // ./Unwrap
+
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		Base:       base,
+		MaxRetries: defaultMaxRetries,
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+	}
+} // ./NewTransport
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	idempotent := !isMutation(body)
+	ctx := req.Context()
+
+	attempt := 0
+	for {
+		attempt++
+		err := t.waitForBucket(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		rq := req.Clone(ctx)
+		rq.Body = io.NopCloser(bytes.NewReader(body))
+		rq.ContentLength = int64(len(body))
+		res, err := t.Base.RoundTrip(rq)
+		if err != nil {
+			if !idempotent || ctx.Err() != nil {
+				return nil, err
+			}
+			if attempt > t.MaxRetries {
+				return nil, &RetryError{Attempts: attempt, Err: err}
+			}
+			wait := t.backoff(attempt)
+			t.logger().Log(LevelWarn, "request failed, retrying", "attempt", attempt, "wait", wait, "error", err)
+			err = sleep(ctx, wait)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if res.StatusCode == http.StatusTooManyRequests {
+			res.Body.Close()
+			if attempt > t.MaxRetries {
+				return nil, &RetryError{Attempts: attempt, StatusCode: res.StatusCode, Throttled: true}
+			}
+			wait := retryAfter(res.Header)
+			if wait <= 0 {
+				wait = t.backoff(attempt)
+			}
+			t.logger().Log(LevelWarn, "throttled, retrying", "attempt", attempt, "status", res.StatusCode, "wait", wait)
+			err = sleep(ctx, wait)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if res.StatusCode >= 500 {
+			res.Body.Close()
+			if !idempotent {
+				return nil, &RetryError{Attempts: attempt, StatusCode: res.StatusCode}
+			}
+			if attempt > t.MaxRetries {
+				return nil, &RetryError{Attempts: attempt, StatusCode: res.StatusCode}
+			}
+			wait := t.backoff(attempt)
+			t.logger().Log(LevelWarn, "request failed, retrying", "attempt", attempt, "status", res.StatusCode, "wait", wait)
+			err = sleep(ctx, wait)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			if !idempotent || attempt > t.MaxRetries {
+				return nil, &RetryError{Attempts: attempt, Err: err}
+			}
+			err = sleep(ctx, t.backoff(attempt))
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var env costEnvelope
+		if json.Unmarshal(data, &env) == nil {
+			if c := env.Extensions.Cost; c != nil {
+				t.update(*c)
+				t.logger().Log(LevelDebug, "query cost", "cost", c.RequestedQueryCost, "available", c.ThrottleStatus.CurrentlyAvailable)
+			}
+			if throttled(env) {
+				if attempt > t.MaxRetries {
+					return nil, &RetryError{Attempts: attempt, StatusCode: res.StatusCode, Throttled: true}
+				}
+				wait := t.backoff(attempt)
+				if env.Extensions.Cost != nil {
+					wait = maxDuration(wait, refillTime(*env.Extensions.Cost))
+				}
+				t.logger().Log(LevelWarn, "throttled, retrying", "attempt", attempt, "wait", wait)
+				err = sleep(ctx, wait)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		res.Body = io.NopCloser(bytes.NewReader(data))
+		res.ContentLength = int64(len(data))
+		return res, nil
+	}
+} // ./RoundTrip
+
+// waitForBucket blocks until the bucket has refilled enough to pay for a
+// request as expensive as the previous one.
+func (t *Transport) waitForBucket(ctx context.Context) error {
+	t.mu.Lock()
+	b := t.bucket
+	seen := t.seen
+	cost := t.lastCost
+	t.mu.Unlock()
+
+	if seen.IsZero() || b.RestoreRate <= 0 {
+		return nil
+	}
+	available := b.CurrentlyAvailable + b.RestoreRate*time.Since(seen).Seconds()
+	if available > b.MaximumAvailable {
+		available = b.MaximumAvailable
+	}
+	if cost <= available {
+		return nil
+	}
+	wait := time.Duration((cost - available) / b.RestoreRate * float64(time.Second))
+	t.logger().Log(LevelDebug, "waiting for the cost bucket", "cost", cost, "available", available, "wait", wait)
+	return sleep(ctx, wait)
+} // ./waitForBucket
+
+func (t *Transport) update(c queryCost) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bucket = c.ThrottleStatus
+	t.seen = time.Now()
+	t.lastCost = c.RequestedQueryCost
+} // ./update
+
+func (t *Transport) logger() Logger {
+	if t.Logger == nil {
+		return nopLogger{}
+	}
+	return t.Logger
+} // ./logger
+
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := float64(t.MinBackoff) * math.Pow(2, float64(attempt-1))
+	if d > float64(t.MaxBackoff) {
+		d = float64(t.MaxBackoff)
+	}
+	// full jitter over the upper half so retries never collapse to zero
+	return time.Duration(d/2 + rand.Float64()*d/2)
+} // ./backoff
+
+func throttled(env costEnvelope) bool {
+	for _, e := range env.Errors {
+		if e.Extensions.Code == "THROTTLED" {
+			return true
+		}
+	}
+	return false
+} // ./throttled
+
+func refillTime(c queryCost) time.Duration {
+	if c.ThrottleStatus.RestoreRate <= 0 {
+		return 0
+	}
+	missing := c.RequestedQueryCost - c.ThrottleStatus.CurrentlyAvailable
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / c.ThrottleStatus.RestoreRate * float64(time.Second))
+} // ./refillTime
+
+func isMutation(body []byte) bool {
+	var rq struct {
+		Query string `json:"query"`
+	}
+	if json.Unmarshal(body, &rq) != nil {
+		// unknown payload, treat it as unsafe to repeat
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(rq.Query), "mutation")
+} // ./isMutation
+
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	secs, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(secs * float64(time.Second))
+} // ./retryAfter
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+} // ./sleep
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+} // ./maxDuration
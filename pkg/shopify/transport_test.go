@@ -0,0 +1,102 @@
+package shopify
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reply is one answer of the stub Base, a network error when err is set.
+type reply struct {
+	status     int
+	retryAfter string
+	err        error
+}
+
+type stubBase struct {
+	replies  []reply
+	attempts int
+}
+
+func (b *stubBase) RoundTrip(rq *http.Request) (*http.Response, error) {
+	r := b.replies[len(b.replies)-1]
+	if b.attempts < len(b.replies) {
+		r = b.replies[b.attempts]
+	}
+	b.attempts++
+	if r.err != nil {
+		return nil, r.err
+	}
+	res := &http.Response{
+		StatusCode: r.status,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"data":{}}`)),
+		Request:    rq,
+	}
+	if r.retryAfter != "" {
+		res.Header.Set("Retry-After", r.retryAfter)
+	}
+	return res, nil
+} // ./RoundTrip
+
+func TestTransportRetries(t *testing.T) {
+	const (
+		query    = `{"query":"query locations { locations(first: 5) { nodes { id } } }"}`
+		mutation = `{"query":"mutation inventorySetQuantities($input: InventorySetQuantitiesInput!) { inventorySetQuantities(input: $input) { userErrors { message } } }"}`
+	)
+	reset := errors.New("connection reset by peer")
+	for _, c := range []struct {
+		name     string
+		body     string
+		replies  []reply
+		attempts int
+		// status is the final status, 0 when RoundTrip returns an error
+		status int
+		// retryStatus is the StatusCode of the *RetryError, if any
+		retryStatus int
+		// wait is how long the retries must at least have waited
+		wait time.Duration
+	}{
+		{"429 with Retry-After", query, []reply{{status: 429, retryAfter: "0.05"}, {status: 200}}, 2, 200, 0, 50 * time.Millisecond},
+		{"429 on a mutation", mutation, []reply{{status: 429, retryAfter: "0.01"}, {status: 200}}, 2, 200, 0, 0},
+		{"429 until out of retries", query, []reply{{status: 429}}, 4, 0, 429, 0},
+		{"5xx on a query", query, []reply{{status: 502}, {status: 503}, {status: 200}}, 3, 200, 0, 0},
+		{"5xx until out of retries", query, []reply{{status: 500}}, 4, 0, 500, 0},
+		{"5xx on a mutation", mutation, []reply{{status: 502}, {status: 200}}, 1, 0, 502, 0},
+		{"network error on a query", query, []reply{{err: reset}, {status: 200}}, 2, 200, 0, 0},
+		{"network error on a mutation", mutation, []reply{{err: reset}, {status: 200}}, 1, 0, 0, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			base := &stubBase{replies: c.replies}
+			tr := &Transport{Base: base, MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+			rq, _ := http.NewRequest("POST", "https://shop.example/admin/api/graphql.json", strings.NewReader(c.body))
+			start := time.Now()
+			res, err := tr.RoundTrip(rq)
+			if waited := time.Since(start); waited < c.wait {
+				t.Errorf("waited %v, want at least %v", waited, c.wait)
+			}
+			if base.attempts != c.attempts {
+				t.Errorf("%d attempts, want %d", base.attempts, c.attempts)
+			}
+			if c.status != 0 {
+				if err != nil || res.StatusCode != c.status {
+					t.Fatalf("got %v, %v, want status %d", res, err, c.status)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got status %d, want an error", res.StatusCode)
+			}
+			var re *RetryError
+			if c.retryStatus != 0 && (!errors.As(err, &re) || re.StatusCode != c.retryStatus) {
+				t.Errorf("got %v, want a RetryError with status %d", err, c.retryStatus)
+			}
+			if c.retryStatus == 0 && !errors.Is(err, reset) {
+				t.Errorf("got %v, want %v", err, reset)
+			}
+		})
+	}
+} // ./TestTransportRetries
@@ -0,0 +1,266 @@
+package solomon
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type RecordWriter interface {
+	Write(record []string) error
+}
+
+type RecordReader interface {
+	Read() (record []string, err error)
+}
+
+// headerWriter is implemented by writers that format the header row
+// differently, such as *Writer.
+type headerWriter interface {
+	WriteHeader(record []string) error
+}
+
+type column struct {
+	name  string
+	index int
+}
+
+var layouts sync.Map // reflect.Type -> []column
+
+func layout(t reflect.Type) ([]column, error) {
+	if cc, ok := layouts.Load(t); ok {
+		return cc.([]column), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("solomon: %s is not a struct", t)
+	}
+	cc := []column{}
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := t.Field(i).Tag.Lookup("solomon")
+		if !ok || name == "-" {
+			continue
+		}
+		cc = append(cc, column{name: name, index: i})
+	}
+	layouts.Store(t, cc)
+	return cc, nil
+} // ./layout
+
+func structType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+} // ./structType
+
+// Header returns the column names of a record type.
+func Header(v interface{}) ([]string, error) {
+	cc, err := layout(structType(v))
+	if err != nil {
+		return nil, err
+	}
+	h := make([]string, len(cc))
+	for i, c := range cc {
+		h[i] = c.name
+	}
+	return h, nil
+} // ./Header
+
+// Encoder writes records of a single struct type.
+type Encoder struct {
+	w           RecordWriter
+	typ         reflect.Type
+	cols        []column
+	wroteHeader bool
+}
+
+// NewEncoder returns an Encoder for records shaped like v.
+func NewEncoder(w RecordWriter, v interface{}) (*Encoder, error) {
+	t := structType(v)
+	cc, err := layout(t)
+	if err != nil {
+		return nil, err
+	}
+	return &Encoder{w: w, typ: t, cols: cc}, nil
+} // ./NewEncoder
+
+// WriteHeader writes the header row. Encode calls it on first use, call
+// it directly so that a file without records still has a header.
+func (e *Encoder) WriteHeader() error {
+	if e.wroteHeader {
+		return nil
+	}
+	e.wroteHeader = true
+	h := make([]string, len(e.cols))
+	for i, c := range e.cols {
+		h[i] = c.name
+	}
+	if hw, ok := e.w.(headerWriter); ok {
+		return hw.WriteHeader(h)
+	}
+	return e.w.Write(h)
+} // ./WriteHeader
+
+func (e *Encoder) Encode(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Type() != e.typ {
+		return fmt.Errorf("solomon: encoder for %s got %s", e.typ, rv.Type())
+	}
+	err := e.WriteHeader()
+	if err != nil {
+		return err
+	}
+	record := make([]string, len(e.cols))
+	for i, c := range e.cols {
+		s, err := formatValue(rv.Field(c.index))
+		if err != nil {
+			return fmt.Errorf("solomon: %s: %w", c.name, err)
+		}
+		record[i] = s
+	}
+	return e.w.Write(record)
+} // ./Encode
+
+func formatValue(v reflect.Value) (string, error) {
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	if st, ok := v.Interface().(fmt.Stringer); ok {
+		return st.String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return "True", nil
+		}
+		return "False", nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+} // ./formatValue
+
+// ParseError is returned by Decode for a value that does not fit its
+// field. The rest of the record is still decoded and the next Decode
+// goes on with the next record.
+type ParseError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("solomon: line %d: %s: %s", e.Line, e.Column, e.Err)
+} // ./Error
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+} // ./Unwrap
+
+// Decoder reads records by header name, so columns may come in any order
+// and columns missing from the file are left zero.
+type Decoder struct {
+	r      RecordReader
+	header map[string]int
+	line   int
+}
+
+func NewDecoder(r RecordReader) *Decoder {
+	return &Decoder{r: r}
+} // ./NewDecoder
+
+// Decode reads the next record into v, a pointer to a record struct. It
+// returns io.EOF when there are no more records and a *ParseError for a
+// value that does not parse.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("solomon: Decode needs a non-nil pointer")
+	}
+	rv = rv.Elem()
+	cc, err := layout(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	if d.header == nil {
+		h, err := d.r.Read()
+		if err != nil {
+			return err
+		}
+		d.line++
+		d.header = map[string]int{}
+		for i, name := range h {
+			d.header[strings.TrimSpace(name)] = i
+		}
+	}
+	record, err := d.r.Read()
+	if err != nil {
+		return err
+	}
+	d.line++
+
+	rv.Set(reflect.Zero(rv.Type()))
+	var perr *ParseError
+	for _, c := range cc {
+		i, ok := d.header[c.name]
+		if !ok || i >= len(record) {
+			continue
+		}
+		err := parseValue(rv.Field(c.index), record[i])
+		if err != nil && perr == nil {
+			perr = &ParseError{Line: d.line, Column: c.name, Err: err}
+		}
+	}
+	if perr != nil {
+		return perr
+	}
+	return nil
+} // ./Decode
+
+// Header returns the column names read from the file, or nil before the
+// first Decode.
+func (d *Decoder) Header() []string {
+	if d.header == nil {
+		return nil
+	}
+	h := make([]string, len(d.header))
+	for name, i := range d.header {
+		h[i] = name
+	}
+	return h
+} // ./Header
+
+func parseValue(v reflect.Value, s string) error {
+	if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strings.TrimSpace(s)
+		if s == "" {
+			v.SetInt(0)
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Bool:
+		v.SetBool(strings.EqualFold(strings.TrimSpace(s), "true"))
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", v.Type())
+} // ./parseValue
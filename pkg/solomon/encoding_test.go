@@ -0,0 +1,85 @@
+package solomon
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	in := []InventoryQuantity{
+		{InventoryID: "CUE-1", Description: "Predator Cue", StockingUOM: "EA", Quantity: 8, Location: "Warehouse"},
+		{InventoryID: "TIP-3", Description: "Tips, \"medium\"", Quantity: 0},
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = '\t'
+	enc, err := NewEncoder(w, InventoryQuantity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range in {
+		err = enc.Encode(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+
+	r := csv.NewReader(&buf)
+	r.Comma = '\t'
+	dec := NewDecoder(r)
+	out := []InventoryQuantity{}
+	for {
+		var q InventoryQuantity
+		err := dec.Decode(&q)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, q)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip\n got %+v\nwant %+v", out, in)
+	}
+	h, _ := Header(InventoryQuantity{})
+	if !reflect.DeepEqual(dec.Header(), h) {
+		t.Errorf("header %v, want %v", dec.Header(), h)
+	}
+} // ./TestEncodeDecode
+
+func TestDecodeByName(t *testing.T) {
+	r := csv.NewReader(bytes.NewBufferString("Quantity,InventoryID\n7,RACK-9\n"))
+	var q InventoryQuantity
+	err := NewDecoder(r).Decode(&q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.InventoryID != "RACK-9" || q.Quantity != 7 || q.Location != "" {
+		t.Errorf("got %+v", q)
+	}
+} // ./TestDecodeByName
+
+func TestDecodeParseError(t *testing.T) {
+	r := csv.NewReader(bytes.NewBufferString("InventoryID,Quantity\nCUE-1,8x\nTIP-3,4\n"))
+	dec := NewDecoder(r)
+	var q InventoryQuantity
+	err := dec.Decode(&q)
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != 2 || perr.Column != "Quantity" {
+		t.Fatalf("got %v, want a ParseError for Quantity on line 2", err)
+	}
+	if q.InventoryID != "CUE-1" {
+		t.Errorf("rest of the record not decoded: %+v", q)
+	}
+	// the bad record does not stop the next one
+	err = dec.Decode(&q)
+	if err != nil || q.InventoryID != "TIP-3" || q.Quantity != 4 {
+		t.Errorf("next record: %+v, %v", q, err)
+	}
+} // ./TestDecodeParseError
@@ -0,0 +1,103 @@
+package solomon
+
+// The column layouts of the files Solomon imports. Field order is column
+// order and the solomon tag is the header name.
+
+type StoreOrder struct {
+	OrderID            string `solomon:"ORDER_ID"`
+	CustID             string `solomon:"CustId"`
+	OrderNr            string `solomon:"ORDER_NR"`
+	AdminCode          string `solomon:"ADMIN_CODE"`
+	MemberID           string `solomon:"MEMBER_ID"`
+	BillingFirstName   string `solomon:"BILLING_FIRST_NAME"`
+	BillingLastName    string `solomon:"BILLING_LAST_NAME"`
+	BillingCompany     string `solomon:"BILLING_COMPANY"`
+	BillingAddress1    string `solomon:"BILLING_ADDRESS1"`
+	BillingAddress2    string `solomon:"BILLING_ADDRESS2"`
+	BillingCity        string `solomon:"BILLING_CITY"`
+	BillingState       string `solomon:"BILLING_STATE"`
+	BillingCountry     string `solomon:"BILLING_COUNTRY"`
+	BillingZip         string `solomon:"BILLING_ZIP"`
+	BillingPhone       string `solomon:"BILLING_PHONE"`
+	ShippingFirstName  string `solomon:"SHIPPING_FIRST_NAME"`
+	ShippingLastName   string `solomon:"SHIPPING_LAST_NAME"`
+	ShippingCompany    string `solomon:"SHIPPING_COMPANY"`
+	ShippingAddress1   string `solomon:"SHIPPING_ADDRESS1"`
+	ShippingAddress2   string `solomon:"SHIPPING_ADDRESS2"`
+	ShippingCity       string `solomon:"SHIPPING_CITY"`
+	ShippingState      string `solomon:"SHIPPING_STATE"`
+	ShippingCountry    string `solomon:"SHIPPING_COUNTRY"`
+	ShippingZip        string `solomon:"SHIPPING_ZIP"`
+	ShippingPhone      string `solomon:"SHIPPING_PHONE"`
+	ShippingCode       string `solomon:"SHIPPING_CODE"`
+	Terms              string `solomon:"Terms"`
+	Email              string `solomon:"EMAIL"`
+	BaseSubtotal       string `solomon:"BASE_SUBTOTAL"`
+	Subtotal           string `solomon:"SUBTOTAL"`
+	TaxAmount          string `solomon:"TAX_AMOUNT"`
+	ShippingAmount     string `solomon:"SHIPPING_AMOUNT"`
+	Total              string `solomon:"TOTAL"`
+	CreateDate         string `solomon:"CREATE_DATE"`
+	ProcessDate        string `solomon:"PROCESS_DATE"`
+	SettleDate         string `solomon:"SETTLE_DATE"`
+	InvoicedDate       string `solomon:"INVOICED_DATE"`
+	ShippedDate        string `solomon:"SHIPPED_DATE"`
+	SmallOrderFee      string `solomon:"SMALL_ORDER_FEE"`
+	LargeOrderDiscount string `solomon:"LARGE_ORDER_DISCOUNT"`
+}
+
+type StoreCartItem struct {
+	CartItemID               string `solomon:"CART_ITEM_ID"`
+	OrderNr                  string `solomon:"ORDER_NR"`
+	ItemVariantID            string `solomon:"ITEM_VARIANT_ID"`
+	ItemPrice                string `solomon:"ITEM_PRICE"`
+	SalePrice                string `solomon:"SALE_PRICE"`
+	IsOnSale                 string `solomon:"IS_ON_SALE"`
+	ItemNumber               string `solomon:"ITEM_NUMBER"`
+	UnitOfMeasure            string `solomon:"UNIT_OF_MEASURE"`
+	ItemQuantity             int    `solomon:"ITEM_QUANTITY"`
+	ItemName                 string `solomon:"ITEM_NAME"`
+	Weight                   string `solomon:"WEIGHT"`
+	Price                    string `solomon:"PRICE"`
+	ExtraPrice               string `solomon:"EXTRA_PRICE"`
+	OptionID                 string `solomon:"OPTION_ID"`
+	OptionItemNumberModifier string `solomon:"OPTION_ITEM_NUMBER_MODIFIER"`
+}
+
+type Member struct {
+	MemberID        string `solomon:"MEMBER_ID"`
+	CustID          string `solomon:"CustId"`
+	Email           string `solomon:"EMAIL"`
+	FirstName       string `solomon:"FIRST_NAME"`
+	LastName        string `solomon:"LAST_NAME"`
+	CompanyName     string `solomon:"COMPANY_NAME"`
+	Address1        string `solomon:"ADDRESS1"`
+	Address2        string `solomon:"ADDRESS2"`
+	City            string `solomon:"CITY"`
+	StateCode       string `solomon:"STATE_CODE"`
+	Zip             string `solomon:"ZIP"`
+	CountryCode     string `solomon:"COUNTRY_CODE"`
+	Region          string `solomon:"REGION"`
+	Phone           string `solomon:"PHONE"`
+	Fax             string `solomon:"FAX"`
+	Cell            string `solomon:"CELL"`
+	Terms           string `solomon:"Terms"`
+	PriceClass      string `solomon:"PRICE_CLASS"`
+	ApprovalPending string `solomon:"APPROVAL_PENDING"`
+	DateCreated     string `solomon:"DATE_CREATED"`
+	LastUpdated     string `solomon:"LAST_UPDATED"`
+	Notes           string `solomon:"NOTES"`
+}
+
+// InventoryQuantity is a row of "ABS Inventory Quantities.txt" (tab
+// separated). Location is optional when reading.
+type InventoryQuantity struct {
+	InventoryID   string `solomon:"InventoryID"`
+	Description   string `solomon:"Description"`
+	StockingUOM   string `solomon:"StockingUOM"`
+	PurchasingUOM string `solomon:"PurchasingUOM"`
+	SellingUOM    string `solomon:"SellingUOM"`
+	StatusCode    string `solomon:"StatusCode"`
+	Quantity      int    `solomon:"Quantity"`
+	Location      string `solomon:"Location"`
+}
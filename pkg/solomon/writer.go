@@ -0,0 +1,131 @@
+// Package solomon reads and writes the text files exchanged with the
+// Solomon ERP.
+package solomon
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Writer writes comma separated records the way Solomon imports them:
+// numeric fields bare, integral values without a fraction, every other
+// field in double quotes and CRLF line endings. This is the format the
+// old format_csv.py produced with csv.QUOTE_NONNUMERIC, down to dropping
+// leading zeros of numeric looking fields such as zip codes.
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+} // ./NewWriter
+
+// WriteHeader writes record with every field quoted.
+func (w *Writer) WriteHeader(record []string) error {
+	fields := make([]string, len(record))
+	for i, f := range record {
+		fields[i] = quote(f)
+	}
+	return w.line(fields)
+} // ./WriteHeader
+
+func (w *Writer) Write(record []string) error {
+	fields := make([]string, len(record))
+	for i, f := range record {
+		if n, ok := Number(f); ok {
+			fields[i] = n
+			continue
+		}
+		fields[i] = quote(f)
+	}
+	return w.line(fields)
+} // ./Write
+
+func (w *Writer) Flush() {
+	if w.err != nil {
+		return
+	}
+	w.err = w.w.Flush()
+} // ./Flush
+
+func (w *Writer) Error() error {
+	return w.err
+} // ./Error
+
+func (w *Writer) line(fields []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	_, w.err = w.w.WriteString(strings.Join(fields, ",") + "\r\n")
+	return w.err
+} // ./line
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+} // ./quote
+
+// Number reports whether s is written as a number and how: anything
+// Python's float() accepts, integral values as integers, the rest in
+// shortest form.
+func Number(s string) (string, bool) {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return "", false
+	}
+	lower := strings.ToLower(strings.TrimLeft(t, "+-"))
+	if strings.HasPrefix(lower, "0x") {
+		return "", false
+	}
+	if strings.Contains(t, "_") {
+		if !validUnderscores(t) {
+			return "", false
+		}
+		t = strings.ReplaceAll(t, "_", "")
+	}
+	v, err := strconv.ParseFloat(t, 64)
+	if err != nil && !(isRangeErr(err) && math.IsInf(v, 0)) {
+		return "", false
+	}
+	switch {
+	case math.IsNaN(v):
+		return "nan", true
+	case math.IsInf(v, 1):
+		return "inf", true
+	case math.IsInf(v, -1):
+		return "-inf", true
+	case v == 0:
+		return "0", true
+	case v == math.Trunc(v):
+		return strconv.FormatFloat(v, 'f', 0, 64), true
+	case math.Abs(v) < 1e-4:
+		return strconv.FormatFloat(v, 'e', -1, 64), true
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64), true
+} // ./Number
+
+// validUnderscores applies Python's rule that underscores may only sit
+// between two digits.
+func validUnderscores(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(s)-1 || !isDigit(s[i-1]) || !isDigit(s[i+1]) {
+			return false
+		}
+	}
+	return true
+} // ./validUnderscores
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+} // ./isDigit
+
+func isRangeErr(err error) bool {
+	ne, ok := err.(*strconv.NumError)
+	return ok && ne.Err == strconv.ErrRange
+} // ./isRangeErr
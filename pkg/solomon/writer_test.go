@@ -0,0 +1,58 @@
+package solomon
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteHeader([]string{"ORDER_ID", "TOTAL", "NOTES"})
+	w.Write([]string{"5001", "285.59", `Corner Pocket, "The" Hall`})
+	w.Write([]string{"0.00", "01234", ""})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.Fatal(err)
+	}
+	want := "\"ORDER_ID\",\"TOTAL\",\"NOTES\"\r\n" +
+		"5001,285.59,\"Corner Pocket, \"\"The\"\" Hall\"\r\n" +
+		"0,1234,\"\"\r\n"
+	if buf.String() != want {
+		t.Errorf("got\n%q\nwant\n%q", buf.String(), want)
+	}
+} // ./TestWriter
+
+func TestNumber(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"12", "12", true},
+		{"12.50", "12.5", true},
+		{"1.2500", "1.25", true},
+		{"-0.00", "0", true},
+		{" 7 ", "7", true},
+		{"1_000", "1000", true},
+		{"1e3", "1000", true},
+		{"0.00001", "1e-05", true},
+		{"1234567.5", "1234567.5", true},
+		{"5125550100", "5125550100", true},
+		{"NaN", "nan", true},
+		{"-Infinity", "-inf", true},
+		{"1e400", "inf", true},
+		{"", "", false},
+		{"False", "", false},
+		{"CUE-1", "", false},
+		{"0x10", "", false},
+		{"1__0", "", false},
+		{"_10", "", false},
+		{"02/28/2023 09:30:00 AM", "", false},
+	} {
+		got, ok := Number(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Number(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+} // ./TestNumber